@@ -7,19 +7,28 @@ package tcl // import "modernc.org/tcl"
 import (
 	"bufio"
 	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
 	"flag"
 	"fmt"
 	"io"
 	"io/ioutil"
+	"math/big"
+	"net"
 	"os"
 	"os/exec"
 	"path"
 	"path/filepath"
 	"runtime"
 	"runtime/debug"
+	"sort"
 	"strings"
 	"sync/atomic"
 	"testing"
+	"testing/fstest"
+	"time"
+	"unsafe"
 
 	"modernc.org/ccgo/v3/lib"
 	"modernc.org/libc"
@@ -1393,3 +1402,2105 @@ func TestS390xBug(t *testing.T) {
 		t.Errorf("got %q exp %q", g, e)
 	}
 }
+
+func TestEvalError(t *testing.T) {
+	in, err := NewInterp()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer func() {
+		if err := in.Close(); err != nil {
+			t.Error(err)
+		}
+	}()
+
+	_, err = in.Eval(`proc f {} {
+	error "boom" "" {GO TEST 42}
+}
+f`)
+	var e *Error
+	if !errors.As(err, &e) {
+		t.Fatalf("unexpected error type %T", err)
+	}
+
+	if g, e := e.Code, tcl.TCL_ERROR; g != e {
+		t.Errorf("got %v exp %v", g, e)
+	}
+	if g, e := e.Error(), "boom"; g != e {
+		t.Errorf("got %q exp %q", g, e)
+	}
+	if g, e := strings.Join(e.ErrorCode, " "), "GO TEST 42"; g != e {
+		t.Errorf("got %q exp %q", g, e)
+	}
+	if g, e := e.ErrorLine, 4; g != e {
+		t.Errorf("got %v exp %v", g, e)
+	}
+	if !strings.Contains(e.ErrorInfo, `(procedure "f" line 2)`) {
+		t.Errorf("unexpected -errorinfo %q", e.ErrorInfo)
+	}
+	if _, ok := e.Options["-level"]; !ok {
+		t.Errorf("missing -level in %v", e.Options)
+	}
+}
+
+func TestNewObjCommand(t *testing.T) {
+	in, err := NewInterp()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer func() {
+		if err := in.Close(); err != nil {
+			t.Error(err)
+		}
+	}()
+
+	var delTrace string
+	_, err = in.NewObjCommand(
+		"::go::rev",
+		func(clientData interface{}, in *Interp, args []*Obj) (*Obj, error) {
+			if len(args) == 1 {
+				return nil, &Error{Code: tcl.TCL_ERROR, Msg: "no args", ErrorCode: []string{"GO", "NOARGS"}}
+			}
+
+			var a []string
+			for i := len(args) - 1; i > 0; i-- {
+				a = append(a, args[i].String())
+			}
+			return NewStringObj(strings.Join(a, " ")), nil
+		},
+		42,
+		func(clientData interface{}) {
+			delTrace = fmt.Sprint(clientData)
+		},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := in.Eval("::go::rev 1 {2 3} 4")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if g, e := s, "4 2 3 1"; g != e {
+		t.Errorf("got %q exp %q", g, e)
+	}
+
+	_, err = in.Eval("::go::rev")
+	var e *Error
+	if !errors.As(err, &e) {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	if g, e := fmt.Sprintf("%s %q", e.Msg, e.ErrorCode), `no args ["GO" "NOARGS"]`; g != e {
+		t.Errorf("got %q exp %q", g, e)
+	}
+
+	if _, err := in.Eval("rename ::go::rev {}"); err != nil {
+		t.Fatal(err)
+	}
+
+	if g, e := delTrace, "42"; g != e {
+		t.Errorf("got %q exp %q", g, e)
+	}
+}
+
+func TestObj(t *testing.T) {
+	if n, err := NewIntObj(-42).Int(); err != nil || n != -42 {
+		t.Errorf("got %v, %v", n, err)
+	}
+
+	if f, err := NewDoubleObj(1.5).Double(); err != nil || f != 1.5 {
+		t.Errorf("got %v, %v", f, err)
+	}
+
+	if b, err := NewStringObj("yes").Bool(); err != nil || !b {
+		t.Errorf("got %v, %v", b, err)
+	}
+
+	if g, e := NewBoolObj(false).String(), "0"; g != e {
+		t.Errorf("got %q exp %q", g, e)
+	}
+
+	if _, err := NewStringObj("foo").Int(); err == nil {
+		t.Errorf("unexpected success")
+	}
+
+	if g, e := NewByteArrayObj([]byte{0, 1, 255}).Bytes(), []byte{0, 1, 255}; !bytes.Equal(g, e) {
+		t.Errorf("got %v exp %v", g, e)
+	}
+
+	n, _ := new(big.Int).SetString("-123456789012345678901234567890", 10)
+	o := NewBigIntObj(n)
+	if g, e := o.String(), n.String(); g != e {
+		t.Errorf("got %q exp %q", g, e)
+	}
+
+	if m, err := o.BigInt(); err != nil || m.Cmp(n) != 0 {
+		t.Errorf("got %v, %v", m, err)
+	}
+
+	list := NewListObj(NewStringObj("a b"), NewIntObj(1), NewListObj())
+	if g, e := list.String(), "{a b} 1 {}"; g != e {
+		t.Errorf("got %q exp %q", g, e)
+	}
+
+	a, err := list.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if g, e := len(a), 3; g != e {
+		t.Fatalf("got %v exp %v", g, e)
+	}
+
+	if g, e := a[0].String(), "a b"; g != e {
+		t.Errorf("got %q exp %q", g, e)
+	}
+
+	if _, err := NewStringObj("{").List(); err == nil {
+		t.Errorf("unexpected success")
+	}
+
+	dict := NewDictObj(map[string]*Obj{"b": NewIntObj(2), "a": NewStringObj("x y")})
+	if g, e := dict.String(), "a {x y} b 2"; g != e {
+		t.Errorf("got %q exp %q", g, e)
+	}
+
+	m, err := dict.Dict()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if n, err := m["b"].Int(); err != nil || n != 2 {
+		t.Errorf("got %v, %v", n, err)
+	}
+
+	if _, err := NewStringObj("a").Dict(); err == nil {
+		t.Errorf("unexpected success")
+	}
+}
+
+func TestRegisterFunc(t *testing.T) {
+	in, err := NewInterp()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer func() {
+		if err := in.Close(); err != nil {
+			t.Error(err)
+		}
+	}()
+
+	type point struct {
+		X, Y int
+		Name string `tcl:"name"`
+	}
+
+	in.MustRegisterFunc("add", func(a, b int) int { return a + b })
+	in.MustRegisterFunc("sum", func(a ...float64) (r float64) {
+		for _, v := range a {
+			r += v
+		}
+		return r
+	})
+	in.MustRegisterFunc("div", func(a, b int) (int, error) {
+		if b == 0 {
+			return 0, fmt.Errorf("divide by zero")
+		}
+
+		return a / b, nil
+	})
+	in.MustRegisterFunc("keys", func(m map[string]int) (r []string) {
+		for k := range m {
+			r = append(r, k)
+		}
+		sort.Strings(r)
+		return r
+	})
+	in.MustRegisterFunc("move", func(p point, dx int) point {
+		p.X += dx
+		return p
+	})
+	in.MustRegisterFunc("divmod", func(in *Interp, a, b int) (int, int) { return a / b, a % b })
+
+	for i, v := range []struct {
+		script, result string
+		fail           bool
+	}{
+		{"add 1 2", "3", false},
+		{"add 1", `wrong # args: should be "add integer integer"`, true},
+		{"::add 1 2 3", `wrong # args: should be "::add integer integer"`, true},
+		{"add 1 x", `expected integer but got "x"`, true},
+		{"sum", "0.0", false},
+		{"sum 1 2.5", "3.5", false},
+		{"div 7 2", "3", false},
+		{"div 7 0", "divide by zero", true},
+		{"keys {b 1 a 2}", "a b", false},
+		{"move {X 1 Y 2 name p} 10", "X 11 Y 2 name p", false},
+		{"divmod 7 2", "3 1", false},
+	} {
+		s, err := in.Eval(v.script)
+		if g, e := err != nil, v.fail; g != e {
+			t.Errorf("%v: %q: got error %v", i, v.script, err)
+			continue
+		}
+
+		if g, e := s, v.result; g != e {
+			t.Errorf("%v: %q: got %q exp %q", i, v.script, g, e)
+		}
+	}
+}
+
+func TestVar(t *testing.T) {
+	in, err := NewInterp()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer func() {
+		if err := in.Close(); err != nil {
+			t.Error(err)
+		}
+	}()
+
+	if err := in.SetVar("a", 42, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := in.SetVar("a", "{x", AppendValue); err != nil {
+		t.Fatal(err)
+	}
+
+	if s, err := in.Eval("set a"); err != nil || s != "42{x" {
+		t.Errorf("got %q, %v", s, err)
+	}
+
+	if err := in.SetVar("l", []string{"a b", "c"}, GlobalOnly); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := in.SetVar("l", "d", ListElement); err != nil {
+		t.Fatal(err)
+	}
+
+	o, err := in.GetVar("l", GlobalOnly)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if g, e := o.String(), "{a b} c d"; g != e {
+		t.Errorf("got %q exp %q", g, e)
+	}
+
+	_, err = in.GetVar("nosuchvar", 0)
+	var e *Error
+	if !errors.As(err, &e) {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	if g, e := strings.Join(e.ErrorCode, " "), "TCL LOOKUP VARNAME nosuchvar"; g != e {
+		t.Errorf("got %q exp %q", g, e)
+	}
+
+	if err := in.UnsetVar("a", 0); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := in.UnsetVar("a", 0); err == nil {
+		t.Fatal("unexpected success")
+	}
+
+	if _, err := in.Eval("namespace eval ::ns { variable v 1 }"); err != nil {
+		t.Fatal(err)
+	}
+
+	if o, err := in.GetVar("::ns::v", 0); err != nil || o.String() != "1" {
+		t.Errorf("got %v, %v", o, err)
+	}
+
+	if err := in.SetVar2("arr", "x", 1, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := in.ArraySet("arr", map[string]interface{}{"y": 2.5, "z": "foo"}, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	if o, err := in.GetVar2("arr", "y", 0); err != nil || o.String() != "2.5" {
+		t.Errorf("got %v, %v", o, err)
+	}
+
+	if err := in.UnsetVar2("arr", "z", 0); err != nil {
+		t.Fatal(err)
+	}
+
+	m, err := in.ArrayGet("arr", GlobalOnly)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if g, e := fmt.Sprint(m), "map[x:1 y:2.5]"; g != e {
+		t.Errorf("got %q exp %q", g, e)
+	}
+
+	if err := in.SetVar("s", 1, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, v := range []struct {
+		name, msg string
+	}{
+		{"nosuchvar", `can't read "nosuchvar": no such variable`},
+		{"s", `can't read "s": no such array`},
+		{"arr(x)", `can't read "arr(x)": no such array`},
+	} {
+		_, err := in.ArrayGet(v.name, 0)
+		var e *Error
+		if !errors.As(err, &e) {
+			t.Fatalf("%s: unexpected error %v", v.name, err)
+		}
+
+		if g := e.Msg; g != v.msg {
+			t.Errorf("%s: got %q exp %q", v.name, g, v.msg)
+		}
+	}
+
+	// ArrayGet does not use the array command, it runs read traces but
+	// leaves the interpreter result alone.
+	in.MustEval("rename array {}; trace add variable arr read {unset -nocomplain arr(y);#}; set r result")
+	if m, err = in.ArrayGet("arr", 0); err != nil {
+		t.Fatal(err)
+	}
+
+	if g, e := fmt.Sprint(m), "map[x:1]"; g != e {
+		t.Errorf("got %q exp %q", g, e)
+	}
+
+	if g, e := objString(in.tls, tcl.XTcl_GetObjResult(in.tls, in.interp)), "result"; g != e {
+		t.Errorf("got %q exp %q", g, e)
+	}
+}
+
+func TestTraceVar(t *testing.T) {
+	in, err := NewInterp()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer func() {
+		if err := in.Close(); err != nil {
+			t.Error(err)
+		}
+	}()
+
+	var log []string
+	tr, err := in.TraceVar("cfg", TraceWrites|TraceUnsets, func(op TraceOp, name1, name2 string) error {
+		log = append(log, fmt.Sprintf("%v %s(%s)", op, name1, name2))
+		if name2 == "bad" {
+			return fmt.Errorf("read only")
+		}
+
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := in.Eval("set cfg(a) 1; set cfg(b) 2; unset cfg(a)"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := in.Eval("set cfg(bad) 1"); err == nil || !strings.Contains(err.Error(), "read only") {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	if _, err := in.Eval("set cfg(b)"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := tr.Remove(); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := in.Eval("set cfg(c) 3"); err != nil {
+		t.Fatal(err)
+	}
+
+	if g, e := strings.Join(log, ", "), "write cfg(a), write cfg(b), unset cfg(a), write cfg(bad)"; g != e {
+		t.Errorf("got %q exp %q", g, e)
+	}
+
+	log = nil
+	tr, err = in.TraceVar("x", TraceReads, func(op TraceOp, name1, name2 string) error {
+		log = append(log, fmt.Sprintf("%v %s(%s)", op, name1, name2))
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	objectMu.Lock()
+	n := len(objects)
+	objectMu.Unlock()
+	if _, err := in.Eval("set x 1; set x; unset x; set x 2; set x"); err != nil {
+		t.Fatal(err)
+	}
+
+	objectMu.Lock()
+	n2 := len(objects)
+	objectMu.Unlock()
+	if g, e := n2, n-1; g != e {
+		t.Errorf("got %v exp %v", g, e)
+	}
+
+	if g, e := strings.Join(log, ", "), "read x()"; g != e {
+		t.Errorf("got %q exp %q", g, e)
+	}
+
+	if err := tr.Remove(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLinkVar(t *testing.T) {
+	in, err := NewInterp()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer func() {
+		if err := in.Close(); err != nil {
+			t.Error(err)
+		}
+	}()
+
+	var (
+		i  int64 = 42
+		f        = 1.5
+		b        = true
+		s        = "foo"
+		ro int64 = 7
+	)
+	in.MustLinkVar("i", &i, false)
+	in.MustLinkVar("f", &f, false)
+	in.MustLinkVar("b", &b, false)
+	in.MustLinkVar("s", &s, false)
+	in.MustLinkVar("ro", &ro, true)
+	if g, e := in.MustEval("list $i $f $b $s $ro"), "42 1.5 1 foo 7"; g != e {
+		t.Errorf("got %q exp %q", g, e)
+	}
+
+	i, f, b, s = 1, 2.5, false, "bar baz"
+	if g, e := in.MustEval("list $i $f $b $s"), "1 2.5 0 {bar baz}"; g != e {
+		t.Errorf("got %q exp %q", g, e)
+	}
+
+	in.MustEval("set i 100; set f 1e3; set b yes; set s qux")
+	if g, e := fmt.Sprintf("%v %v %v %v", i, f, b, s), "100 1000 true qux"; g != e {
+		t.Errorf("got %q exp %q", g, e)
+	}
+
+	if _, err := in.Eval("set i foo"); err == nil || err.Error() != `can't set "i": variable must have integer value` {
+		t.Errorf("unexpected error %v", err)
+	}
+
+	if g, e := in.MustEval("set i"), "100"; g != e {
+		t.Errorf("got %q exp %q", g, e)
+	}
+
+	if _, err := in.Eval("set ro 8"); err == nil || err.Error() != `can't set "ro": linked variable is read-only` {
+		t.Errorf("unexpected error %v", err)
+	}
+
+	if g, e := ro, int64(7); g != e {
+		t.Errorf("got %v exp %v", g, e)
+	}
+
+	in.MustEval("unset i")
+	i = 3
+	if g, e := in.MustEval("set i"), "3"; g != e {
+		t.Errorf("got %q exp %q", g, e)
+	}
+
+	if err := in.UnlinkVar("i"); err != nil {
+		t.Fatal(err)
+	}
+
+	in.MustEval("set i 4")
+	if g, e := i, int64(3); g != e {
+		t.Errorf("got %v exp %v", g, e)
+	}
+
+	if err := in.LinkVar("x", 42, false); err == nil {
+		t.Errorf("unexpected success")
+	}
+}
+
+func TestEvalContext(t *testing.T) {
+	in, err := NewInterp()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer func() {
+		if err := in.Close(); err != nil {
+			t.Error(err)
+		}
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+
+	defer cancel()
+
+	t0 := time.Now()
+	_, err = in.EvalContext(ctx, "while 1 {}")
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	if d := time.Since(t0); d > 10*time.Second {
+		t.Errorf("cancellation took %v", d)
+	}
+
+	if _, err := in.EvalContext(ctx, "set a 1"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	s, err := in.EvalContext(context.Background(), "set a 1; incr a")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if g, e := s, "2"; g != e {
+		t.Errorf("got %q exp %q", g, e)
+	}
+
+	ctx2, cancel2 := context.WithCancel(context.Background())
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		cancel2()
+	}()
+	if _, err = in.EvalContext(ctx2, "proc f {} { while 1 {} }; catch f"); !errors.Is(err, context.Canceled) {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	if s, err := in.Eval("set a"); err != nil || s != "2" {
+		t.Errorf("got %q, %v", s, err)
+	}
+}
+
+func TestLimits(t *testing.T) {
+	in, err := NewInterp()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer func() {
+		if err := in.Close(); err != nil {
+			t.Error(err)
+		}
+	}()
+
+	var hits []LimitType
+	in.MustAddLimitHandler(LimitCommands, func(in *Interp, typ LimitType) { hits = append(hits, typ) })
+	if err := in.SetCommandLimit(1000); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = in.Eval("proc f {} {while 1 {incr i}}; f")
+	var le *LimitError
+	if !errors.As(err, &le) {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	if g, e := le.Type, LimitCommands; g != e {
+		t.Errorf("got %v exp %v", g, e)
+	}
+
+	var e *Error
+	if !errors.As(err, &e) {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	if g, e := fmt.Sprint(hits), "[commands]"; g != e {
+		t.Errorf("got %q exp %q", g, e)
+	}
+
+	if _, err := in.Eval("set a 1"); err == nil {
+		t.Fatal("unexpected success")
+	}
+
+	if err := in.RemoveLimit(LimitCommands); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := in.Eval("set a 1"); err != nil {
+		t.Fatal(err)
+	}
+
+	// A handler raising the limit lets the evaluation continue.
+	c := in.MustAddLimitHandler(LimitCommands, func(in *Interp, typ LimitType) {
+		if err := in.SetCommandLimit(1000); err != nil {
+			t.Error(err)
+		}
+	})
+	if err := in.SetCommandLimit(100); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := in.Eval("proc g {} {for {set i 0} {$i < 1000} {incr i} {}}; g"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := c.Remove(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := in.RemoveLimit(LimitCommands); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := in.SetTimeLimit(time.Now().Add(100 * time.Millisecond)); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := in.SetLimitGranularity(LimitTime, 1); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = in.Eval("while 1 {incr j}"); !errors.As(err, &le) || le.Type != LimitTime {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	if err := in.RemoveLimit(LimitTime); err != nil {
+		t.Fatal(err)
+	}
+
+	if s, err := in.Eval("set a"); err != nil || s != "1" {
+		t.Errorf("got %q, %v", s, err)
+	}
+}
+
+func TestChild(t *testing.T) {
+	in, err := NewInterp()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer func() {
+		if err := in.Close(); err != nil {
+			t.Error(err)
+		}
+	}()
+
+	child, err := in.NewChild("plugin", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	isSafe := func(in *Interp) bool {
+		r, err := in.IsSafe()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return r
+	}
+
+	if !isSafe(child) || isSafe(in) {
+		t.Fatal("unexpected safety")
+	}
+
+	if child.Parent() != in {
+		t.Fatal("unexpected parent")
+	}
+
+	if _, err := child.Eval("open /dev/null"); err == nil {
+		t.Fatal("unexpected success")
+	}
+
+	var parents []*Interp
+	commands := in.MustEval("lsort [info commands]")
+	if err := child.Alias("greet", func(clientData interface{}, in *Interp, args []string) int {
+		parents = append(parents, in)
+		in.SetResult(fmt.Sprintf("%s %s", args[0], strings.Join(args[1:], " ")))
+		return tcl.TCL_OK
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	if g, e := in.MustEval("lsort [info commands]"), commands; g != e {
+		t.Errorf("got %q exp %q", g, e)
+	}
+
+	if err := child.Alias("fail", func(clientData interface{}, in *Interp, args []string) int {
+		in.SetResult("failed")
+		return tcl.TCL_ERROR
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	if s, err := child.Eval("catch fail msg; set msg"); err != nil || s != "failed" {
+		t.Fatalf("got %q, %v", s, err)
+	}
+
+	if s, err := in.Eval("lsort [interp aliases plugin]"); err != nil || s != "fail greet" {
+		t.Fatalf("got %q, %v", s, err)
+	}
+
+	if s, err := in.Eval("string match ::tcl::go::alias* [interp alias plugin greet]"); err != nil || s != "1" {
+		t.Fatalf("got %q, %v", s, err)
+	}
+
+	if s, err := child.Eval("greet hello world"); err != nil || s != "greet hello world" {
+		t.Fatalf("got %q, %v", s, err)
+	}
+
+	if len(parents) != 1 || parents[0] != in {
+		t.Fatalf("unexpected interpreters %v", parents)
+	}
+
+	if err := child.Hide("greet"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := child.Eval("greet"); err == nil {
+		t.Fatal("unexpected success")
+	}
+
+	if s, err := in.Eval("interp invokehidden plugin greet x"); err != nil || s != "greet x" {
+		t.Fatalf("got %q, %v", s, err)
+	}
+
+	if err := child.Expose("greet"); err != nil {
+		t.Fatal(err)
+	}
+
+	if s, err := child.Eval("greet y"); err != nil || s != "greet y" {
+		t.Fatalf("got %q, %v", s, err)
+	}
+
+	if err := in.Alias("x", nil); err == nil {
+		t.Fatal("unexpected success")
+	}
+
+	// Deleting the child by a script invalidates it.
+	if _, err := in.Eval("interp delete plugin"); err != nil {
+		t.Fatal(err)
+	}
+
+	if child.Handle() != 0 || len(child.aliases) != 0 {
+		t.Fatal("child not invalidated")
+	}
+
+	if s, err := in.Eval("info commands ::tcl::go::alias*"); err != nil || s != "" {
+		t.Fatalf("got %q, %v", s, err)
+	}
+
+	child2 := in.MustNewChild("other", false)
+	if isSafe(child2) {
+		t.Fatal("unexpected safety")
+	}
+
+	if err := child2.MakeSafe(); err != nil {
+		t.Fatal(err)
+	}
+
+	if !isSafe(child2) {
+		t.Fatal("MakeSafe failed")
+	}
+
+	child2.MustAlias("f", func(clientData interface{}, in *Interp, args []string) int { return tcl.TCL_OK })
+	if err := child2.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestBoundInterp(t *testing.T) {
+	in, err := NewBoundInterp()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var calls int32
+	in.Do(func(in *Interp) {
+		in.MustNewCommand("g", func(clientData interface{}, in *Interp, args []string) int {
+			atomic.AddInt32(&calls, 1)
+			return tcl.TCL_OK
+		}, nil, nil)
+	})
+	const n = 10
+	errs := make(chan error, n)
+	for i := 0; i < n; i++ {
+		go func(i int) {
+			s, err := in.Eval(fmt.Sprintf("g; incr a %d", i))
+			if err == nil && s == "" {
+				err = fmt.Errorf("empty result")
+			}
+			errs <- err
+		}(i)
+	}
+	for i := 0; i < n; i++ {
+		if err := <-errs; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if s := in.MustEval("set a"); s != "45" {
+		t.Fatalf("got %q", s)
+	}
+
+	if g, e := atomic.LoadInt32(&calls), int32(n); g != e {
+		t.Fatalf("got %v exp %v", g, e)
+	}
+
+	if err := in.SetVar("b", 42, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	if o, err := in.GetVar("b", 0); err != nil || o.String() != "42" {
+		t.Fatalf("got %v, %v", o, err)
+	}
+
+	func() {
+		defer func() {
+			if e := recover(); e != "foo" {
+				t.Fatalf("unexpected panic %v", e)
+			}
+		}()
+
+		in.Do(func(in *Interp) { panic("foo") })
+	}()
+
+	if err := in.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := in.Do(func(in *Interp) { t.Error("called after Close") }); err != ErrClosed {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	if _, err := in.Eval("set a"); err != ErrClosed {
+		t.Fatalf("unexpected error %v", err)
+	}
+}
+
+func TestConcurrentUse(t *testing.T) {
+	in, err := NewInterp()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer func() {
+		if err := in.Close(); err != nil {
+			t.Error(err)
+		}
+	}()
+
+	entered := make(chan struct{})
+	in.MustNewCommand("entered", func(clientData interface{}, in *Interp, args []string) int {
+		// Reentrant use by the same goroutine is fine.
+		if _, err := in.Eval("set b 1"); err != nil {
+			t.Error(err)
+		}
+		close(entered)
+		return tcl.TCL_OK
+	}, nil, nil)
+	done := make(chan error)
+	go func() {
+		_, err := in.Eval("entered; while {![info exists stop]} update")
+		done <- err
+	}()
+	<-entered
+	// The use is detected once the interpreter executes the loop.
+	for deadline := time.Now().Add(10 * time.Second); ; {
+		if _, err := in.Eval("set a 1"); err == ErrConcurrentUse {
+			break
+		}
+
+		if time.Now().After(deadline) {
+			t.Fatal("concurrent use not detected")
+		}
+	}
+
+	if err := in.SetVar("a", 1, 0); err != ErrConcurrentUse {
+		t.Errorf("unexpected error %v", err)
+	}
+
+	if _, err := in.Compile("set a 1"); err != ErrConcurrentUse {
+		t.Errorf("unexpected error %v", err)
+	}
+
+	in.Post(func(in *Interp) {
+		if err := in.SetVar("stop", 1, 0); err != nil {
+			t.Error(err)
+		}
+	})
+
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+
+	if s, err := in.Eval("set b"); err != nil || s != "1" {
+		t.Fatalf("got %q, %v", s, err)
+	}
+
+	// A use while the interpreter executes a command implemented in Go is
+	// executed as a nested use.
+	block := make(chan struct{})
+	release := make(chan struct{})
+	in.MustNewCommand("block", func(clientData interface{}, in *Interp, args []string) int {
+		close(block)
+		<-release
+		return tcl.TCL_OK
+	}, nil, nil)
+	results := make(chan string, 1)
+	go func() {
+		s, err := in.Eval("block; set c")
+		results <- s
+		done <- err
+	}()
+	<-block
+	if _, err := in.Eval("set c 2"); err != nil {
+		t.Error(err)
+	}
+
+	close(release)
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+
+	if g, e := <-results, "2"; g != e {
+		t.Fatalf("got %q exp %q", g, e)
+	}
+}
+
+func TestPool(t *testing.T) {
+	var inits int32
+	p, err := NewPool(2, func(in *Interp) error {
+		atomic.AddInt32(&inits, 1)
+		if _, err := in.NewCommand("hello", func(clientData interface{}, in *Interp, args []string) int {
+			in.SetResult("world")
+			return tcl.TCL_OK
+		}, nil, nil); err != nil {
+			return err
+		}
+
+		_, err := in.Eval("set counter 0; array set cfg {a 1}; proc f {x {y 2}} {expr {$x+$y}}")
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer func() {
+		if err := p.Close(); err != nil {
+			t.Error(err)
+		}
+	}()
+
+	ctx := context.Background()
+	in, err := p.Get(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	in.MustEval("incr counter; set cfg(b) 2; set extra 1; proc f {} {return changed}; proc g {} {}; namespace eval ::foo {}; after 1000 {}")
+	p.Put(in)
+	a, _ := p.Get(ctx)
+	b, _ := p.Get(ctx)
+	for _, in := range []*Interp{a, b} {
+		s, err := in.Eval(`list $counter [array get cfg] [info exists extra] [f 1] [info commands g] [namespace exists ::foo] [after info] [hello]`)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if g, e := s, "0 {a 1} 0 3 {} 0 {} world"; g != e {
+			t.Errorf("got %q exp %q", g, e)
+		}
+	}
+
+	// An interpreter that cannot be reset is replaced when needed.
+	a.MustEval("rename hello {}; proc hello {} {return fake}")
+	p.Put(a)
+	p.Put(b)
+	if g, e := atomic.LoadInt32(&inits), int32(2); g != e {
+		t.Fatalf("got %v exp %v", g, e)
+	}
+
+	p.SetHealthCheck(func(in *Interp) error {
+		if s, err := in.Eval("hello"); err != nil || s != "world" {
+			return fmt.Errorf("unhealthy")
+		}
+
+		return nil
+	})
+	const n = 20
+	errs := make(chan error, n)
+	for i := 0; i < n; i++ {
+		go func(i int) {
+			errs <- p.With(ctx, func(in *Interp) error {
+				s, err := in.Eval(fmt.Sprintf("incr counter %d", i))
+				if err == nil && s != fmt.Sprint(i) {
+					err = fmt.Errorf("got %q exp %v", s, i)
+				}
+				return err
+			})
+		}(i)
+	}
+	for i := 0; i < n; i++ {
+		if err := <-errs; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if g, e := atomic.LoadInt32(&inits), int32(3); g != e {
+		t.Fatalf("got %v exp %v", g, e)
+	}
+
+	a, _ = p.Get(ctx)
+	b, _ = p.Get(ctx)
+	ctx2, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	if _, err := p.Get(ctx2); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	// A closed interpreter is discarded.
+	if err := a.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	p.Put(a)
+	if a, err = p.Get(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	if s, err := a.Eval("hello"); err != nil || s != "world" {
+		t.Fatalf("got %q, %v", s, err)
+	}
+
+	if g, e := atomic.LoadInt32(&inits), int32(4); g != e {
+		t.Fatalf("got %v exp %v", g, e)
+	}
+
+	p.Put(a)
+	p.Put(b)
+}
+
+func TestEvalFile(t *testing.T) {
+	in, err := NewInterp()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer func() {
+		if err := in.Close(); err != nil {
+			t.Error(err)
+		}
+	}()
+
+	dir := t.TempDir()
+	fn := filepath.Join(dir, "test.tcl")
+	if err := os.WriteFile(fn, []byte("set a 1\nset b [info script]\nerror foo\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = in.EvalFile(fn)
+	var e *Error
+	if !errors.As(err, &e) {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	if g, e := e.ErrorInfo, fmt.Sprintf("(file \"%s\" line 3)", fn); !strings.Contains(g, e) {
+		t.Errorf("%q does not contain %q", g, e)
+	}
+
+	if s, err := in.Eval("list $a $b [info script]"); err != nil || s != fmt.Sprintf("1 %s {}", fn) {
+		t.Errorf("got %q, %v", s, err)
+	}
+
+	_, err = in.EvalNamed("set c [info script]\nset d [dict get [info frame 0] file]\nset e [dict get [info frame 0] line]\nset a\nerror bar", "named.tcl")
+	if !errors.As(err, &e) {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	if g, e := e.ErrorInfo, "(file \"named.tcl\" line 5)"; !strings.Contains(g, e) {
+		t.Errorf("%q does not contain %q", g, e)
+	}
+
+	if s, err := in.Eval("list $c $d $e [info script]"); err != nil || s != "named.tcl named.tcl 3 {}" {
+		t.Errorf("got %q, %v", s, err)
+	}
+
+	if s, err := in.EvalNamed("return -code ok 42", "x.tcl"); err != nil || s != "42" {
+		t.Errorf("got %q, %v", s, err)
+	}
+
+	fsys := fstest.MapFS{"lib/x.tcl": &fstest.MapFile{Data: []byte("set f [info script]")}}
+	if s, err := in.EvalFS(fsys, "lib/x.tcl"); err != nil || s != "lib/x.tcl" {
+		t.Errorf("got %q, %v", s, err)
+	}
+
+	if _, err := in.EvalFS(fsys, "missing.tcl"); err == nil {
+		t.Error("unexpected success")
+	}
+
+	in.MustNewCommand("global", func(clientData interface{}, in *Interp, args []string) int {
+		if _, err := in.EvalGlobal("set g [info level]"); err != nil {
+			in.SetResult(err.Error())
+			return tcl.TCL_ERROR
+		}
+
+		return tcl.TCL_OK
+	}, nil, nil)
+	if s, err := in.Eval("proc p {} {global; info exists g}; list [p] $g"); err != nil || s != "0 0" {
+		t.Errorf("got %q, %v", s, err)
+	}
+
+	if s, err := in.EvalDirect("set h 1; incr h"); err != nil || s != "2" {
+		t.Errorf("got %q, %v", s, err)
+	}
+}
+
+type testChannel struct {
+	bytes.Buffer
+	closed bool
+	opts   map[string]string
+}
+
+func (c *testChannel) Close() error { c.closed = true; return nil }
+
+func (c *testChannel) ChannelOptions() []string { return []string{"-color", "-size"} }
+
+func (c *testChannel) GetChannelOption(name string) (string, error) { return c.opts[name], nil }
+
+func (c *testChannel) SetChannelOption(name, value string) error {
+	if name == "-size" {
+		return fmt.Errorf("read only option")
+	}
+
+	c.opts[name] = value
+	return nil
+}
+
+func TestRegisterChannel(t *testing.T) {
+	in, err := NewInterp()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer func() {
+		if err := in.Close(); err != nil {
+			t.Error(err)
+		}
+	}()
+
+	c := &testChannel{opts: map[string]string{"-color": "red", "-size": "42"}}
+	if err := in.RegisterChannel("test", c, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	if s, err := in.Eval("puts test hello; flush test; gets test"); err != nil || s != "hello" {
+		t.Fatalf("got %q, %v", s, err)
+	}
+
+	if s, err := in.Eval("fconfigure test -color blue; list [fconfigure test -color] [fconfigure test -size]"); err != nil || s != "blue 42" {
+		t.Fatalf("got %q, %v", s, err)
+	}
+
+	if s, err := in.Eval("dict get [fconfigure test] -color"); err != nil || s != "blue" {
+		t.Fatalf("got %q, %v", s, err)
+	}
+
+	if _, err := in.Eval("fconfigure test -size 1"); err == nil {
+		t.Fatal("unexpected success")
+	}
+
+	if _, err := in.Eval("fconfigure test -foo"); err == nil || !strings.Contains(err.Error(), "color") {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	if _, err := in.Eval("close test"); err != nil || !c.closed {
+		t.Fatalf("close failed: %v", err)
+	}
+
+	in.MustRegisterChannel("ro", strings.NewReader("0123456789"), Readable)
+	if s, err := in.Eval("seek ro 5; list [read ro 2] [tell ro]"); err != nil || s != "56 7" {
+		t.Fatalf("got %q, %v", s, err)
+	}
+
+	if _, err := in.Eval("puts ro x"); err == nil {
+		t.Fatal("unexpected success")
+	}
+
+	if err := in.RegisterChannel("wo", strings.NewReader(""), Writable); err == nil {
+		t.Fatal("unexpected success")
+	}
+
+	var out bytes.Buffer
+	in.MustRegisterChannel("wo", &out, Writable)
+	if _, err := in.Eval("puts -nonewline wo abc; close wo"); err != nil || out.String() != "abc" {
+		t.Fatalf("got %q, %v", out.String(), err)
+	}
+
+	r, w := io.Pipe()
+	in.MustRegisterChannel("pipe", r, 0)
+	if s, err := in.Eval("fconfigure pipe -blocking 0; list [gets pipe] [fblocked pipe]"); err != nil || s != "{} 1" {
+		t.Fatalf("got %q, %v", s, err)
+	}
+
+	go func() {
+		w.Write([]byte("line\n"))
+		w.Close()
+	}()
+	if s, err := in.Eval("fconfigure pipe -blocking 1; list [gets pipe] [gets pipe] [eof pipe]"); err != nil || s != "line {} 1" {
+		t.Fatalf("got %q, %v", s, err)
+	}
+
+	r, w = io.Pipe()
+	in.MustRegisterChannel("watched", r, 0)
+	go func() {
+		w.Write([]byte("event\n"))
+		w.Close()
+	}()
+	if s, err := in.Eval("fileevent watched readable {set got [gets watched]}; vwait got; close watched; set got"); err != nil || s != "event" {
+		t.Fatalf("got %q, %v", s, err)
+	}
+}
+
+func TestChannel(t *testing.T) {
+	in, err := NewInterp()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer func() {
+		if err := in.Close(); err != nil {
+			t.Error(err)
+		}
+	}()
+
+	if _, err := in.Channel("nosuchchannel"); err == nil {
+		t.Fatal("unexpected success")
+	}
+
+	fn := filepath.Join(t.TempDir(), "test")
+	if err := in.SetVar("fn", fn, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	name := in.MustEval("set f [open $fn w+]")
+	c, err := in.Channel(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if g, e := c.Name(), name; g != e {
+		t.Errorf("got %q exp %q", g, e)
+	}
+
+	if err := c.Configure("-translation", "lf"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := c.Configure("-nosuchoption", "1"); err == nil {
+		t.Fatal("unexpected success")
+	}
+
+	if _, err := fmt.Fprintf(c, "hello\nworld é\n"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := c.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	if s, err := in.Eval("seek $f 0; gets $f"); err != nil || s != "hello" {
+		t.Fatalf("got %q, %v", s, err)
+	}
+
+	b, err := io.ReadAll(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if g, e := string(b), "world é\n"; g != e {
+		t.Errorf("got %q exp %q", g, e)
+	}
+
+	if err := c.Configure("-translation", "binary"); err != nil {
+		t.Fatal(err)
+	}
+
+	in.MustEval("seek $f 0")
+	buf := make([]byte, 3)
+	if _, err := io.ReadFull(c, buf); err != nil || string(buf) != "hel" {
+		t.Fatalf("got %q, %v", buf, err)
+	}
+
+	if err := c.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := c.Write([]byte("x")); err == nil {
+		t.Fatal("unexpected success")
+	}
+
+	// Round trip through a channel implemented in Go.
+	var out bytes.Buffer
+	in.MustRegisterChannel("go", &out, Writable)
+	c = in.MustChannel("go")
+	c.Configure("-translation", "binary")
+	if _, err := c.Write([]byte{0, 1, 255}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := c.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if g, e := out.Bytes(), []byte{0, 1, 255}; !bytes.Equal(g, e) {
+		t.Errorf("got %v exp %v", g, e)
+	}
+
+	// Text channels pass NUL through and join UTF-8 sequences split between
+	// writes.
+	out.Reset()
+	in.MustRegisterChannel("text", &out, Writable)
+	c = in.MustChannel("text")
+	c.Configure("-encoding", "utf-8")
+	c.Configure("-translation", "lf")
+	for _, v := range []string{"a\x00b\xc3", "\xa9", "\xe2\x82", "\xac\n"} {
+		if n, err := c.Write([]byte(v)); err != nil || n != len(v) {
+			t.Fatalf("got %v, %v", n, err)
+		}
+	}
+
+	if err := c.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if g, e := out.String(), "a\x00bé€\n"; g != e {
+		t.Errorf("got %q exp %q", g, e)
+	}
+}
+
+func TestSetStdio(t *testing.T) {
+	if !threaded {
+		t.Skip("standard channels are shared by all interpreters when Tcl is built without threads")
+	}
+
+	var outs, errs [2]bytes.Buffer
+	var ins [2]*Interp
+	for i := range ins {
+		in, err := NewInterp()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		defer in.Close()
+
+		if err := in.SetStdio(strings.NewReader(fmt.Sprintf("input %d\n", i)), &outs[i], &errs[i]); err != nil {
+			t.Fatal(err)
+		}
+
+		ins[i] = in
+	}
+	for i, in := range ins {
+		if _, err := in.Eval(fmt.Sprintf("puts [gets stdin]; puts stderr err%d; puts -nonewline x", i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i := range ins {
+		if g, e := outs[i].String(), fmt.Sprintf("input %d\n", i); g != e {
+			t.Errorf("%d: got %q exp %q", i, g, e)
+		}
+
+		if g, e := errs[i].String(), fmt.Sprintf("err%d\n", i); g != e {
+			t.Errorf("%d: got %q exp %q", i, g, e)
+		}
+	}
+
+	// Closing the interpreter flushes its standard channels.
+	if err := ins[1].Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if g, e := outs[1].String(), "input 1\nx"; g != e {
+		t.Errorf("got %q exp %q", g, e)
+	}
+
+	// Replacing a replaced channel closes it and leaves others unchanged.
+	var out bytes.Buffer
+	ins[0].MustSetStdio(nil, &out, nil)
+	if g, e := outs[0].String(), "input 0\nx"; g != e {
+		t.Errorf("got %q exp %q", g, e)
+	}
+
+	ins[0].MustEval("puts new; puts stderr err; puts -nonewline y")
+	if err := ins[0].Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if g, e := out.String(), "new\ny"; g != e {
+		t.Errorf("got %q exp %q", g, e)
+	}
+
+	if g, e := errs[0].String(), "err0\nerr\n"; g != e {
+		t.Errorf("got %q exp %q", g, e)
+	}
+}
+
+func TestCompile(t *testing.T) {
+	in, err := NewInterp()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer func() {
+		if err := in.Close(); err != nil {
+			t.Error(err)
+		}
+	}()
+
+	for _, v := range []struct {
+		script, msg string
+	}{
+		{"set a {", "missing close-brace"},
+		{"set a 1; set b \"c\"d", "extra characters after close-quote"},
+	} {
+		_, err := in.Compile(v.script)
+		var e *Error
+		if !errors.As(err, &e) {
+			t.Fatalf("%q: unexpected error %v", v.script, err)
+		}
+
+		if g := e.Msg; g != v.msg {
+			t.Errorf("%q: got %q exp %q", v.script, g, v.msg)
+		}
+	}
+
+	s, err := in.Compile("incr n; expr {$x * 2 + [f]}")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if g, e := s.String(), "incr n; expr {$x * 2 + [f]}"; g != e {
+		t.Errorf("got %q exp %q", g, e)
+	}
+
+	in.MustEval("proc f {} {return 1}")
+	for i := 0; i < 100; i++ {
+		r, err := s.EvalWith(map[string]interface{}{"x": i})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if g, e := r, fmt.Sprint(2*i+1); g != e {
+			t.Fatalf("got %q exp %q", g, e)
+		}
+	}
+
+	// Redefining a command used by the script is honored.
+	in.MustEval("proc f {} {return 100}")
+	if r, err := s.Eval(); err != nil || r != "298" {
+		t.Fatalf("got %q, %v", r, err)
+	}
+
+	if n := in.MustEval("set n"); n != "101" {
+		t.Fatalf("got %q", n)
+	}
+
+	// Evaluating the script again does not recompile it.
+	typ, bc := (*tcl.Tcl_Obj)(unsafe.Pointer(s.obj.p)).FtypePtr, *(*uintptr)(unsafe.Pointer(s.obj.p + internalRepOffset))
+	if r, err := s.Eval(); err != nil || r != "298" {
+		t.Fatalf("got %q, %v", r, err)
+	}
+
+	if g, e := (*tcl.Tcl_Obj)(unsafe.Pointer(s.obj.p)).FtypePtr, typ; g != e {
+		t.Errorf("type changed: got %#x exp %#x", g, e)
+	}
+
+	if g, e := *(*uintptr)(unsafe.Pointer(s.obj.p + internalRepOffset)), bc; g != e {
+		t.Errorf("bytecode changed: got %#x exp %#x", g, e)
+	}
+
+	_, err = s.EvalWith(map[string]interface{}{"x": "foo"})
+	var e *Error
+	if !errors.As(err, &e) {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	if _, err := s.EvalWith(map[string]interface{}{"x(": 1}); err == nil {
+		t.Fatal("unexpected success")
+	}
+}
+
+func TestCall(t *testing.T) {
+	in, err := NewInterp()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer func() {
+		if err := in.Close(); err != nil {
+			t.Error(err)
+		}
+	}()
+
+	in.MustEval("proc f args {set ::args $args; llength $args}")
+	tricky := "a {b [exit] $c\\"
+	r, err := in.Call("f", tricky, 42, 1.5, []int{1, 2}, map[string]int{"k": 3}, []byte("xy"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if g, e := r.String(), "6"; g != e {
+		t.Fatalf("got %q exp %q", g, e)
+	}
+
+	if g, e := in.MustEval("lindex $::args 0"), tricky; g != e {
+		t.Errorf("got %q exp %q", g, e)
+	}
+
+	if g, e := in.MustEval("lrange $::args 1 end"), "42 1.5 {1 2} {k 3} xy"; g != e {
+		t.Errorf("got %q exp %q", g, e)
+	}
+
+	if r = in.MustCall("lindex", []string{"x y", "z"}, 0); r.String() != "x y" {
+		t.Errorf("got %q", r)
+	}
+
+	if r = in.MustCall("dict", "get", map[string]int{"a": 1, "b": 2}, "b"); r.String() != "2" {
+		t.Errorf("got %q", r)
+	}
+
+	if n, err := in.MustCall("expr", "6*7").Int(); err != nil || n != 42 {
+		t.Errorf("got %v, %v", n, err)
+	}
+
+	if _, err := in.Call("nosuchcommand", 1); err == nil {
+		t.Fatal("unexpected success")
+	}
+
+	var e *Error
+	if _, err := in.Call("error", "boom"); !errors.As(err, &e) || e.Msg != "boom" {
+		t.Fatalf("unexpected error %v", err)
+	}
+}
+
+func TestNamespace(t *testing.T) {
+	in, err := NewInterp()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer func() {
+		if err := in.Close(); err != nil {
+			t.Error(err)
+		}
+	}()
+
+	ns, err := in.NewNamespace("app::util")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if g, e := ns.Name(), "::app::util"; g != e {
+		t.Fatalf("got %q exp %q", g, e)
+	}
+
+	if _, err := in.NewNamespace("::app::util"); err == nil {
+		t.Fatal("unexpected success")
+	}
+
+	in.MustEval("proc ::app::util::hello {} {return hi}; proc ::app::util::secret {} {}")
+	if err := ns.Export("hel*"); err != nil {
+		t.Fatal(err)
+	}
+
+	global := in.MustNamespace("::")
+	if err := global.Import("::app::util::*", false); err != nil {
+		t.Fatal(err)
+	}
+
+	if g, e := in.MustEval("hello"), "hi"; g != e {
+		t.Errorf("got %q exp %q", g, e)
+	}
+
+	if _, err := in.Eval("secret"); err == nil {
+		t.Error("unexpected success")
+	}
+
+	if err := ns.Delete(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ns.Export("*"); err == nil {
+		t.Fatal("unexpected success")
+	}
+
+	if _, err := in.Namespace("::app::util"); err == nil {
+		t.Fatal("unexpected success")
+	}
+}
+
+func TestEnsemble(t *testing.T) {
+	in, err := NewInterp()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer func() {
+		if err := in.Close(); err != nil {
+			t.Error(err)
+		}
+	}()
+
+	sub := func(nm string) CmdProc {
+		return func(clientData interface{}, in *Interp, args []string) int {
+			in.SetResult(nm + " " + strings.Join(args[1:], " "))
+			return tcl.TCL_OK
+		}
+	}
+	if _, err := in.NewEnsemble("::app::db", map[string]CmdProc{"query": sub("q"), "exec": sub("e")}); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, v := range []struct{ script, exp string }{
+		{"::app::db query a b", "q a b"},
+		{"app::db exec x", "e x"},
+		{"app::db q y", "q y"},
+		{"::app::db::exec z", "e z"},
+		{"namespace ensemble exists ::app::db", "1"},
+	} {
+		if g, err := in.Eval(v.script); err != nil || g != v.exp {
+			t.Errorf("%s: got %q, %v exp %q", v.script, g, err, v.exp)
+		}
+	}
+
+	_, err = in.Eval("app::db drop")
+	if err == nil || !strings.Contains(err.Error(), `unknown or ambiguous subcommand "drop": must be exec, or query`) {
+		t.Errorf("unexpected error %v", err)
+	}
+
+	if _, err := in.NewEnsemble("::app::x", map[string]CmdProc{"a::b": sub("")}); err == nil {
+		t.Error("unexpected success")
+	}
+}
+
+type testCounter struct {
+	N    int
+	Name string
+}
+
+func (c *testCounter) Inc(n int) int { c.N += n; return c.N }
+
+func (c testCounter) Get() int { return c.N }
+
+func (c *testCounter) Label(in *Interp, prefix string) (string, error) {
+	if prefix == "" {
+		return "", fmt.Errorf("empty prefix")
+	}
+
+	return fmt.Sprintf("%s%s=%d", prefix, c.Name, c.N), nil
+}
+
+func TestDefineClass(t *testing.T) {
+	in, err := NewInterp()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer func() {
+		if err := in.Close(); err != nil {
+			t.Error(err)
+		}
+	}()
+
+	if err := in.DefineClass("Counter", &testCounter{Name: "c"}); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, v := range []struct{ script, exp string }{
+		{"set c [Counter new]; $c Inc 5", "5"},
+		{"$c Inc 2", "7"},
+		{"$c Get", "7"},
+		{"$c Label -", "-c=7"},
+		{"set d [Counter new]; $d Get", "0"},
+		{"set e [oo::copy $c]; $e Inc 1", "8"},
+		{"$c Get", "7"},
+		{"info object class $c", "::Counter"},
+		{"oo::class create Sub {superclass Counter}; [Sub new] Inc 3", "3"},
+		{"$c destroy; info object isa object $c", "0"},
+	} {
+		if g, err := in.Eval(v.script); err != nil || g != v.exp {
+			t.Errorf("%s: got %q, %v exp %q", v.script, g, err, v.exp)
+		}
+	}
+
+	for _, v := range []struct{ script, exp string }{
+		{"Counter new 42", `wrong # args: should be "Counter new"`},
+		{"$d Inc", ` Inc integer"`},
+		{"$d Inc x", "expected integer"},
+		{"$d Label {}", "empty prefix"},
+		{"$d Nope", "unknown method"},
+		{"oo::class create Bad {superclass Counter; constructor {} {}}; [Bad new] Get", "no Go value"},
+	} {
+		if _, err := in.Eval(v.script); err == nil || !strings.Contains(err.Error(), v.exp) {
+			t.Errorf("%s: unexpected error %v", v.script, err)
+		}
+	}
+
+	if err := in.DefineClass("X", nil); err == nil {
+		t.Error("unexpected success")
+	}
+}
+
+func TestGoObj(t *testing.T) {
+	in, err := NewInterp()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer func() {
+		if err := in.Close(); err != nil {
+			t.Error(err)
+		}
+	}()
+
+	type handle struct{ n int }
+	h := &handle{42}
+	o := NewGoObj(h)
+	s := o.String()
+	if !strings.HasPrefix(s, "goobj") || o.String() != s {
+		t.Fatalf("unexpected string representation %q", s)
+	}
+
+	if v, err := o.GoValue(); err != nil || v != h {
+		t.Fatalf("got %v, %v", v, err)
+	}
+
+	if err := in.SetVar("h", o, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	in.MustRegisterFunc("value", func(h *handle) int { return h.n })
+	in.MustRegisterFunc("new", func(n int) *Obj { return NewGoObj(&handle{n}) })
+	for _, v := range []struct{ script, exp string }{
+		{"value $h", "42"},
+		{"set l [list a $h]; value [lindex $l 1]", "42"},
+		{"set d [dict create k $h]; value [dict get $d k]", "42"},
+		{"value [new 7]", "7"},
+	} {
+		if g, err := in.Eval(v.script); err != nil || g != v.exp {
+			t.Errorf("%s: got %q, %v exp %q", v.script, g, err, v.exp)
+		}
+	}
+
+	l, err := in.GetVar("l", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	items, err := l.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if v, err := items[1].GoValue(); err != nil || v != h {
+		t.Fatalf("got %v, %v", v, err)
+	}
+
+	if _, err := NewStringObj("goobj").GoValue(); err == nil {
+		t.Fatal("unexpected success")
+	}
+
+	if _, err := in.Eval("value foo"); err == nil {
+		t.Fatal("unexpected success")
+	}
+
+	// The string representation is not a reference.
+	if _, err := NewStringObj(s).GoValue(); err == nil {
+		t.Fatal("unexpected success")
+	}
+
+	if _, err := in.Eval("value [lindex [string range $l 0 end] 1]"); err == nil {
+		t.Fatal("unexpected success")
+	}
+
+	// The Go value is released once no Tcl value references it.
+	goHandle := *goObjHandle(o.p)
+	in.MustEval("unset h l d")
+	o, items, l = nil, nil, nil
+	for i := 0; i < 10; i++ {
+		runtime.GC()
+		in.releaseObjects()
+		NewStringObj("") // Releases the Objs created by NewGoObj.
+		objectMu.Lock()
+		_, ok := objects[goHandle]
+		objectMu.Unlock()
+		if !ok {
+			return
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("%s was not released", s)
+}
+
+func TestProfile(t *testing.T) {
+	in, err := NewInterp()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer func() {
+		if err := in.Close(); err != nil {
+			t.Error(err)
+		}
+	}()
+
+	if err := in.StopProfile(); err == nil {
+		t.Fatal("unexpected success")
+	}
+
+	var buf bytes.Buffer
+	if err := in.StartProfile(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := in.StartProfile(&buf); err == nil {
+		t.Fatal("unexpected success")
+	}
+
+	if _, err := in.EvalNamed(`
+proc fib n {
+	if {$n < 2} {
+		return $n
+	}
+
+	expr {[fib [expr {$n-1}]] + [fib [expr {$n-2}]]}
+}
+fib 15
+`, "prof.tcl"); err != nil {
+		t.Fatal(err)
+	}
+
+	if g, e := in.MustEval("info level"), "0"; g != e {
+		t.Fatalf("got %q exp %q", g, e)
+	}
+
+	if err := in.StopProfile(); err != nil {
+		t.Fatal(err)
+	}
+
+	z, err := gzip.NewReader(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := ioutil.ReadAll(z)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, v := range []string{"calls", "count", "wall", "nanoseconds", "::fib", "::expr", "prof.tcl"} {
+		if !bytes.Contains(b, []byte(v)) {
+			t.Errorf("profile does not contain %q", v)
+		}
+	}
+}
+
+func TestEventLoop(t *testing.T) {
+	in, err := NewInterp()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer func() {
+		if err := in.Close(); err != nil {
+			t.Error(err)
+		}
+	}()
+
+	if g, e := in.MustDoOneEvent(AllEvents|DontWait), false; g != e {
+		t.Fatalf("got %v exp %v", g, e)
+	}
+
+	var fired []string
+	in.MustAfter(20*time.Millisecond, func() { fired = append(fired, "b") })
+	in.MustAfter(10*time.Millisecond, func() { fired = append(fired, "a") })
+	if ok, err := in.MustAfter(time.Millisecond, func() { fired = append(fired, "x") }).Stop(); !ok || err != nil {
+		t.Fatal("Stop failed", err)
+	}
+
+	for len(fired) < 2 {
+		in.MustDoOneEvent(AllEvents)
+	}
+	if g, e := strings.Join(fired, " "), "a b"; g != e {
+		t.Fatalf("got %q exp %q", g, e)
+	}
+
+	// Post wakes up vwait.
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		in.Post(func(in *Interp) { in.MustEval("set v posted") })
+	}()
+	if _, err := in.Eval("vwait v"); err != nil {
+		t.Fatal(err)
+	}
+
+	if g, e := in.MustEval("set v"), "posted"; g != e {
+		t.Fatalf("got %q exp %q", g, e)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	in.MustEval("after 100 {lappend l after}")
+	go func() {
+		in.Post(func(in *Interp) { in.MustEval("lappend l post1") })
+		in.Post(func(in *Interp) { in.MustEval("lappend l post2") })
+		time.Sleep(200 * time.Millisecond)
+		cancel()
+	}()
+	if err := in.RunEventLoop(ctx); err != context.Canceled {
+		t.Fatalf("got %v exp %v", err, context.Canceled)
+	}
+
+	if g, e := in.MustEval("set l"), "post1 post2 after"; g != e {
+		t.Fatalf("got %q exp %q", g, e)
+	}
+
+	// Cancelling an idle event loop.
+	ctx, cancel = context.WithTimeout(context.Background(), 50*time.Millisecond)
+
+	defer cancel()
+
+	if err := in.RunEventLoop(ctx); err != context.DeadlineExceeded {
+		t.Fatalf("got %v exp %v", err, context.DeadlineExceeded)
+	}
+}
+
+func TestAfterClose(t *testing.T) {
+	in, err := NewInterp()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer func() {
+		if err := in.Close(); err != nil {
+			t.Error(err)
+		}
+	}()
+
+	child, err := in.NewChild("child", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	in2, err := NewInterp()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var fired []string
+	t1 := child.MustAfter(0, func() { fired = append(fired, "child") })
+	t2 := in2.MustAfter(0, func() { fired = append(fired, "in2") })
+	if err := child.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := in2.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tm := range []*Timer{t1, t2} {
+		if ok, err := tm.Stop(); ok || err != nil {
+			t.Fatal("stopped a timer of a closed interpreter", err)
+		}
+	}
+
+	in.MustAfter(10*time.Millisecond, func() { fired = append(fired, "in") })
+	for len(fired) == 0 {
+		in.MustDoOneEvent(AllEvents)
+	}
+	if g, e := strings.Join(fired, " "), "in"; g != e {
+		t.Fatalf("got %q exp %q", g, e)
+	}
+}
+
+func TestNotifier(t *testing.T) {
+	in, err := NewInterp()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer func() {
+		if err := in.Close(); err != nil {
+			t.Error(err)
+		}
+	}()
+
+	if _, err := in.Eval(`
+lassign [chan pipe] r w
+fconfigure $r -blocking 0
+fileevent $r readable {set got [gets $r]}
+after 10 {puts $w hello; flush $w}
+vwait got
+close $r
+close $w
+`); err != nil {
+		t.Fatal(err)
+	}
+
+	if g, e := in.MustEval("set got"), "hello"; g != e {
+		t.Fatalf("got %q exp %q", g, e)
+	}
+
+	// A Go client of a Tcl server.
+	port := in.MustEval(`
+proc accept {ch addr port} {
+	fconfigure $ch -buffering line
+	fileevent $ch readable [list serve $ch]
+}
+proc serve ch {
+	if {[gets $ch line] < 0} {
+		close $ch
+		set ::done 1
+		return
+	}
+
+	puts $ch [string toupper $line]
+}
+set s [socket -server accept -myaddr 127.0.0.1 0]
+lindex [fconfigure $s -sockname] 2
+`)
+	reply := make(chan string, 1)
+	go func() {
+		c, err := net.Dial("tcp", "127.0.0.1:"+port)
+		if err != nil {
+			reply <- err.Error()
+			return
+		}
+
+		fmt.Fprintln(c, "ping")
+		s, err := bufio.NewReader(c).ReadString('\n')
+		if err != nil {
+			s = err.Error()
+		}
+		reply <- s
+		c.Close()
+	}()
+	if _, err := in.Eval("vwait done; close $s"); err != nil {
+		t.Fatal(err)
+	}
+
+	if g, e := <-reply, "PONG\n"; g != e {
+		t.Fatalf("got %q exp %q", g, e)
+	}
+}
@@ -0,0 +1,1204 @@
+// Copyright 2026 The Tcl Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Parts of code and or documentation in this file is copied/translated from
+// Tcl C code and subject to a BSD-style license found in the license.terms
+// file.
+
+package tcl // import "modernc.org/tcl"
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strings"
+	"sync"
+	"unicode/utf8"
+	"unsafe"
+
+	"modernc.org/libc"
+	"modernc.org/libc/errno"
+	"modernc.org/mathutil"
+	"modernc.org/tcl/lib"
+)
+
+// Channel modes of RegisterChannel.
+const (
+	Readable = tcl.TCL_READABLE // The channel can be read from.
+	Writable = tcl.TCL_WRITABLE // The channel can be written to.
+)
+
+// ErrWouldBlock is returned by Channel.Read when a channel in nonblocking mode
+// has no data available.
+var ErrWouldBlock = errors.New("tcl: channel operation would block")
+
+// ChannelOptions can be implemented by a value passed to RegisterChannel to
+// provide driver specific options of the channel, accessible using
+// fconfigure.
+type ChannelOptions interface {
+	// ChannelOptions returns the names of the supported options,
+	// including the leading dash.
+	ChannelOptions() []string
+	// GetChannelOption returns the value of the option name.
+	GetChannelOption(name string) (string, error)
+	// SetChannelOption sets the option name to value.
+	SetChannelOption(name, value string) error
+}
+
+// goChannel is the instance data of a channel implemented in Go.
+type goChannel struct {
+	c    io.Closer
+	opts ChannelOptions
+	r    io.Reader
+	s    io.Seeker
+	w    io.Writer
+
+	h      uintptr // The Tcl_Channel.
+	queued bool    // A channel event is queued, used by the thread of n only.
+
+	mu          sync.Mutex
+	n           *notifier // Notifier of the thread watching the channel, if any.
+	nonBlocking bool
+	pending     []byte
+	pendingErr  error
+	pump        chan goChannelChunk // Non nil once the reader goroutine runs.
+	quit        chan struct{}       // Closed when the channel is closed.
+	watch       int32               // Events of interest, see channelWatch.
+}
+
+type goChannelChunk struct {
+	b   []byte
+	err error
+}
+
+// RegisterChannel creates a Tcl channel name backed by rw and registers it in
+// the interpreter, where it can be used by commands like gets, read, puts or
+// close. Rw must implement io.Reader for a Readable channel and io.Writer for
+// a Writable one. If mode is zero, the channel is Readable and/or Writable
+// according to the interfaces rw implements. If rw implements io.Seeker, the
+// channel supports seek and tell. If rw implements io.Closer, it is closed
+// when the channel is closed. Closing the channel for reading or writing only
+// calls the CloseRead or CloseWrite methods of rw, if rw has them. If rw
+// implements ChannelOptions, its options are available using fconfigure.
+//
+// In nonblocking mode, reading is performed by a separate goroutine and a
+// read fails with EAGAIN until data is available.
+func (in *Interp) RegisterChannel(name string, rw interface{}, mode int) error {
+	return in.do(func() error { return in.registerChannel(name, rw, mode) })
+}
+
+func (in *Interp) registerChannel(name string, rw interface{}, mode int) error {
+	h, err := in.newChannel(name, rw, mode)
+	if err != nil {
+		return err
+	}
+
+	tcl.XTcl_RegisterChannel(in.tls, in.interp, h)
+	return nil
+}
+
+// newChannel returns a new Tcl_Channel name backed by rw, see RegisterChannel.
+func (in *Interp) newChannel(name string, rw interface{}, mode int) (uintptr, error) {
+	ch := &goChannel{}
+	ch.r, _ = rw.(io.Reader)
+	ch.w, _ = rw.(io.Writer)
+	ch.s, _ = rw.(io.Seeker)
+	ch.c, _ = rw.(io.Closer)
+	ch.opts, _ = rw.(ChannelOptions)
+	if mode == 0 {
+		if ch.r != nil {
+			mode |= Readable
+		}
+		if ch.w != nil {
+			mode |= Writable
+		}
+	}
+	switch {
+	case mode&^(Readable|Writable) != 0 || mode == 0:
+		return 0, fmt.Errorf("invalid channel mode: %#x", mode)
+	case mode&Readable != 0 && ch.r == nil:
+		return 0, fmt.Errorf("readable channel %s: %T does not implement io.Reader", name, rw)
+	case mode&Writable != 0 && ch.w == nil:
+		return 0, fmt.Errorf("writable channel %s: %T does not implement io.Writer", name, rw)
+	}
+
+	nm, err := libc.CString(name)
+	if err != nil {
+		return 0, err
+	}
+
+	defer libc.Xfree(in.tls, nm)
+
+	h := tcl.XTcl_CreateChannel(in.tls, uintptr(unsafe.Pointer(&channel)), nm, addObject(ch), int32(mode))
+	if h == 0 {
+		return 0, fmt.Errorf("failed to create channel: %s", name)
+	}
+
+	ch.h = h
+	return h, nil
+}
+
+// MustRegisterChannel is like RegisterChannel but panics on error.
+func (in *Interp) MustRegisterChannel(name string, rw interface{}, mode int) {
+	if err := in.RegisterChannel(name, rw, mode); err != nil {
+		panic(err)
+	}
+}
+
+// SetStdio replaces the standard channels stdin, stdout and stderr of the
+// interpreter by channels backed by stdin, stdout and stderr, respectively. A
+// nil argument leaves the corresponding channel unchanged. Stdout is line
+// buffered and stderr is not buffered. The arguments are never closed by the
+// interpreter. The process standard streams are not affected and the children
+// of the interpreter share its standard channels.
+//
+// Tcl keeps the standard channels per thread. Only on targets where Tcl is
+// built with threads, currently linux/amd64, every root interpreter has its
+// own. Elsewhere all interpreters share one Tcl thread and SetStdio redirects
+// the standard channels of all of them until the interpreter is closed.
+func (in *Interp) SetStdio(stdin io.Reader, stdout, stderr io.Writer) error {
+	return in.do(func() error { return in.setStdio(stdin, stdout, stderr) })
+}
+
+func (in *Interp) setStdio(stdin io.Reader, stdout, stderr io.Writer) error {
+	for _, v := range []struct {
+		name      string
+		rw        interface{}
+		mode      int
+		typ       int32
+		buffering string
+	}{
+		{"stdin", stdin, Readable, tcl.TCL_STDIN, ""},
+		{"stdout", stdout, Writable, tcl.TCL_STDOUT, "line"},
+		{"stderr", stderr, Writable, tcl.TCL_STDERR, "none"},
+	} {
+		if v.rw == nil {
+			continue
+		}
+
+		// Hide io.Closer and io.Seeker.
+		switch v.mode {
+		case Readable:
+			v.rw = struct{ io.Reader }{stdin}
+		case Writable:
+			v.rw = struct{ io.Writer }{v.rw.(io.Writer)}
+		}
+
+		if err := in.setStdChannel(v.name, v.rw, v.mode, v.typ, v.buffering); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// MustSetStdio is like SetStdio but panics on error.
+func (in *Interp) MustSetStdio(stdin io.Reader, stdout, stderr io.Writer) {
+	if err := in.SetStdio(stdin, stdout, stderr); err != nil {
+		panic(err)
+	}
+}
+
+func (in *Interp) setStdChannel(name string, rw interface{}, mode int, typ int32, buffering string) error {
+	nm, err := libc.CString(name)
+	if err != nil {
+		return err
+	}
+
+	defer libc.Xfree(in.tls, nm)
+
+	// Looking up the channel makes sure the channel table of the interpreter,
+	// which initially holds the current standard channels, exists.
+	old := tcl.XTcl_GetChannel(in.tls, in.interp, nm, 0)
+	tcl.XTcl_ResetResult(in.tls, in.interp)
+	h, err := in.newChannel(name, rw, mode)
+	if err != nil {
+		return err
+	}
+
+	if buffering != "" {
+		opt, err := libc.CString("-buffering")
+		if err != nil {
+			return err
+		}
+
+		defer libc.Xfree(in.tls, opt)
+
+		v, err := libc.CString(buffering)
+		if err != nil {
+			return err
+		}
+
+		defer libc.Xfree(in.tls, v)
+
+		tcl.XTcl_SetChannelOption(in.tls, 0, h, opt, v)
+	}
+	// The standard channel table holds a reference to its channels. The
+	// standard channel must be replaced before the old one is unregistered,
+	// otherwise Tcl closes it together with the underlying file descriptor.
+	// The original standard channel is kept open by an additional reference,
+	// until restoreStdio hands it back to the standard channel table, a
+	// channel set by an earlier SetStdio is closed.
+	prev := tcl.XTcl_GetStdChannel(in.tls, typ)
+	root := in.root()
+	sc := root.stdio[typ]
+	if sc == nil {
+		if prev != 0 {
+			tcl.XTcl_RegisterChannel(in.tls, 0, prev)
+		}
+		sc = &stdChannel{orig: prev}
+		if root.stdio == nil {
+			root.stdio = map[int32]*stdChannel{}
+		}
+		root.stdio[typ] = sc
+	}
+	sc.cur = h
+	tcl.XTcl_SetStdChannel(in.tls, h, typ)
+	tcl.XTcl_RegisterChannel(in.tls, 0, h)
+	if prev != 0 {
+		tcl.XTcl_UnregisterChannel(in.tls, 0, prev)
+	}
+	if old != 0 {
+		tcl.XTcl_UnregisterChannel(in.tls, in.interp, old)
+	}
+	tcl.XTcl_RegisterChannel(in.tls, in.interp, h)
+	return nil
+}
+
+// stdChannel is a standard channel replaced by SetStdio.
+type stdChannel struct {
+	orig uintptr // The channel replaced by the first SetStdio, if any.
+	cur  uintptr // The channel set by the last SetStdio.
+}
+
+// restoreStdio flushes the standard channels set by SetStdio on the root
+// interpreter in or its children and restores the original ones. The channels
+// set by SetStdio are closed once in is deleted.
+func (in *Interp) restoreStdio() {
+	for typ, sc := range in.stdio {
+		if tcl.XTcl_GetStdChannel(in.tls, typ) != sc.cur {
+			// Without threads all interpreters share the standard
+			// channels and another interpreter replaced sc.cur.
+			if sc.orig != 0 {
+				tcl.XTcl_UnregisterChannel(in.tls, 0, sc.orig)
+			}
+			continue
+		}
+
+		tcl.XTcl_Flush(in.tls, sc.cur)
+		tcl.XTcl_SetStdChannel(in.tls, sc.orig, typ)
+		tcl.XTcl_UnregisterChannel(in.tls, 0, sc.cur)
+	}
+	in.stdio = nil
+}
+
+// Channel is a Tcl channel of an interpreter, for example a file or a socket
+// opened by a script. Channel implements io.ReadWriteCloser. The data read or
+// written are converted according to the -encoding and -translation options of
+// the channel.
+type Channel struct {
+	in      *Interp
+	name    string
+	partial []byte // Incomplete UTF-8 sequence at the end of the last Write.
+	pending []byte // Data read but not yet returned by Read.
+}
+
+// Channel returns the channel name of the interpreter or an error, if any.
+func (in *Interp) Channel(name string) (r *Channel, err error) {
+	err = in.do(func() error { r, err = in.channel(name); return err })
+	return r, err
+}
+
+func (in *Interp) channel(name string) (*Channel, error) {
+	c := &Channel{in: in, name: name}
+	if _, err := c.channel(); err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
+
+// MustChannel is like Channel but panics on error.
+func (in *Interp) MustChannel(name string) *Channel {
+	c, err := in.Channel(name)
+	if err != nil {
+		panic(err)
+	}
+
+	return c
+}
+
+// Name returns the name of c.
+func (c *Channel) Name() string { return c.name }
+
+// channel returns the Tcl_Channel of c. The channel is looked up by name on
+// every use as it can be closed by a script at any time.
+func (c *Channel) channel() (uintptr, error) {
+	nm, err := libc.CString(c.name)
+	if err != nil {
+		return 0, err
+	}
+
+	defer libc.Xfree(c.in.tls, nm)
+
+	h := tcl.XTcl_GetChannel(c.in.tls, c.in.interp, nm, 0)
+	if h == 0 {
+		return 0, c.in.newError(tcl.TCL_ERROR)
+	}
+
+	return h, nil
+}
+
+// binaryChannel reports whether the channel h has the binary encoding, ie. it
+// transfers bytes without conversion.
+func binaryChannel(h uintptr) bool {
+	state := (*tcl.Channel)(unsafe.Pointer(h)).Fstate
+	return (*tcl.ChannelState)(unsafe.Pointer(state)).Fencoding == 0
+}
+
+// incompleteUTF8 returns the index of the incomplete UTF-8 sequence at the end
+// of b or len(b) if there is none.
+func incompleteUTF8(b []byte) int {
+	for i := len(b) - 1; i >= 0 && i >= len(b)-utf8.UTFMax+1; i-- {
+		if utf8.RuneStart(b[i]) {
+			if !utf8.FullRune(b[i:]) {
+				return i
+			}
+
+			break
+		}
+	}
+	return len(b)
+}
+
+// posixError returns an error describing the current Tcl errno.
+func (c *Channel) posixError(op string) error {
+	msg := libc.GoString(tcl.XTcl_ErrnoMsg(c.in.tls, tcl.XTcl_GetErrno(c.in.tls)))
+	return fmt.Errorf("%s %s: %s", op, c.name, msg)
+}
+
+// Read implements io.Reader. It returns ErrWouldBlock if the channel is in
+// nonblocking mode and no data is available.
+func (c *Channel) Read(b []byte) (n int, err error) {
+	err = c.in.do(func() error { n, err = c.read(b); return err })
+	return n, err
+}
+
+func (c *Channel) read(b []byte) (int, error) {
+	if len(b) == 0 {
+		return 0, nil
+	}
+
+	if len(c.pending) != 0 {
+		n := copy(b, c.pending)
+		c.pending = c.pending[n:]
+		return n, nil
+	}
+
+	h, err := c.channel()
+	if err != nil {
+		return 0, err
+	}
+
+	tls := c.in.tls
+	obj := tcl.XTcl_NewObj(tls)
+	incrRefCount(obj)
+
+	defer decrRefCount(tls, obj)
+
+	switch n := tcl.XTcl_ReadChars(tls, h, obj, int32(len(b)), 0); {
+	case n < 0:
+		return 0, c.posixError("read")
+	case n == 0:
+		if tcl.XTcl_Eof(tls, h) != 0 {
+			return 0, io.EOF
+		}
+
+		if tcl.XTcl_InputBlocked(tls, h) != 0 {
+			return 0, ErrWouldBlock
+		}
+
+		return 0, nil
+	}
+
+	var data []byte
+	switch {
+	case binaryChannel(h):
+		bp := tls.Alloc(4)
+
+		defer tls.Free(4)
+
+		p := tcl.XTcl_GetByteArrayFromObj(tls, obj, bp)
+		data = libc.GoBytes(p, int(*(*int32)(unsafe.Pointer(bp))))
+	default:
+		// Tcl encodes NUL as the two byte sequence 0xc0 0x80.
+		data = bytes.ReplaceAll([]byte(objString(tls, obj)), []byte{0xc0, 0x80}, []byte{0})
+	}
+	n := copy(b, data)
+	c.pending = data[n:]
+	return n, nil
+}
+
+// Write implements io.Writer. The data are buffered according to the
+// -buffering option of the channel, see also Flush.
+func (c *Channel) Write(b []byte) (n int, err error) {
+	err = c.in.do(func() error { n, err = c.write(b); return err })
+	return n, err
+}
+
+func (c *Channel) write(b []byte) (int, error) {
+	if len(b) == 0 {
+		return 0, nil
+	}
+
+	h, err := c.channel()
+	if err != nil {
+		return 0, err
+	}
+
+	tls := c.in.tls
+	var obj uintptr
+	switch {
+	case binaryChannel(h):
+		p := tls.Alloc(len(b))
+		copy((*libc.RawMem)(unsafe.Pointer(p))[:len(b):len(b)], b)
+		obj = tcl.XTcl_NewByteArrayObj(tls, p, int32(len(b)))
+		tls.Free(len(b))
+	default:
+		data := b
+		if len(c.partial) != 0 {
+			data = append(c.partial, b...)
+			c.partial = nil
+		}
+		// Keep an incomplete UTF-8 sequence for the next Write, the rest of it
+		// may come there.
+		if i := incompleteUTF8(data); i < len(data) {
+			c.partial = append([]byte(nil), data[i:]...)
+			data = data[:i]
+		}
+		if len(data) == 0 {
+			return len(b), nil
+		}
+
+		// Tcl encodes NUL as the two byte sequence 0xc0 0x80.
+		obj = newStringObj(tls, string(bytes.ReplaceAll(data, []byte{0}, []byte{0xc0, 0x80})))
+	}
+	incrRefCount(obj)
+
+	defer decrRefCount(tls, obj)
+
+	if tcl.XTcl_WriteObj(tls, h, obj) < 0 {
+		return 0, c.posixError("write")
+	}
+
+	return len(b), nil
+}
+
+// Flush writes any buffered output of the channel.
+func (c *Channel) Flush() error {
+	return c.in.do(c.flush)
+}
+
+func (c *Channel) flush() error {
+	h, err := c.channel()
+	if err != nil {
+		return err
+	}
+
+	if tcl.XTcl_Flush(c.in.tls, h) != tcl.TCL_OK {
+		return c.posixError("flush")
+	}
+
+	return nil
+}
+
+// Configure sets the channel option to value like fconfigure does, for
+// example Configure("-translation", "binary").
+func (c *Channel) Configure(option, value string) error {
+	return c.in.do(func() error { return c.configure(option, value) })
+}
+
+func (c *Channel) configure(option, value string) error {
+	h, err := c.channel()
+	if err != nil {
+		return err
+	}
+
+	opt, err := libc.CString(option)
+	if err != nil {
+		return err
+	}
+
+	defer libc.Xfree(c.in.tls, opt)
+
+	v, err := libc.CString(value)
+	if err != nil {
+		return err
+	}
+
+	defer libc.Xfree(c.in.tls, v)
+
+	if tcl.XTcl_SetChannelOption(c.in.tls, c.in.interp, h, opt, v) != tcl.TCL_OK {
+		return c.in.newError(tcl.TCL_ERROR)
+	}
+
+	return nil
+}
+
+// Close implements io.Closer. It closes the channel like the close command
+// does.
+func (c *Channel) Close() error {
+	return c.in.do(c.close)
+}
+
+func (c *Channel) close() error {
+	h, err := c.channel()
+	if err != nil {
+		return err
+	}
+
+	c.pending = nil
+	if tcl.XTcl_UnregisterChannel(c.in.tls, c.in.interp, h) != tcl.TCL_OK {
+		return c.in.newError(tcl.TCL_ERROR)
+	}
+
+	return nil
+}
+
+// channelErrno returns the POSIX error code corresponding to err.
+func channelErrno(err error) int32 {
+	switch {
+	case err == io.ErrClosedPipe || err == os.ErrClosed:
+		return errno.EPIPE
+	case os.IsTimeout(err):
+		return errno.EAGAIN
+	default:
+		return errno.EIO
+	}
+}
+
+func setErrorCode(errorCodePtr uintptr, code int32) {
+	if errorCodePtr != 0 {
+		*(*int32)(unsafe.Pointer(errorCodePtr)) = code
+	}
+}
+
+func goChannelObject(instanceData tcl.ClientData) *goChannel {
+	return getObject(instanceData).(*goChannel)
+}
+
+var (
+	channelSetupP = *(*uintptr)(unsafe.Pointer(&struct {
+		f func(tls *libc.TLS, clientData uintptr, flags int32)
+	}{channelSetup}))
+	channelCheckP = *(*uintptr)(unsafe.Pointer(&struct {
+		f func(tls *libc.TLS, clientData uintptr, flags int32)
+	}{channelCheck}))
+	channelEventP = *(*uintptr)(unsafe.Pointer(&struct {
+		f func(tls *libc.TLS, evPtr uintptr, flags int32) int32
+	}{channelEvent}))
+)
+
+var channel = tcl.Tcl_ChannelType{
+	FtypeName: uintptr(unsafe.Pointer(&cVFSName[0])),
+	Fversion:  tclChannelVersion_2,
+	FcloseProc: *(*uintptr)(unsafe.Pointer(&struct {
+		f func(tls *libc.TLS, instanceData tcl.ClientData, interp uintptr) int32
+	}{channelClose})),
+	FinputProc: *(*uintptr)(unsafe.Pointer(&struct {
+		f func(tls *libc.TLS, instanceData tcl.ClientData, buf uintptr, toRead int32, errorCodePtr uintptr) int32
+	}{channelInput})),
+	FoutputProc: *(*uintptr)(unsafe.Pointer(&struct {
+		f func(tls *libc.TLS, instanceData tcl.ClientData, buf uintptr, toWrite int32, errorCodePtr uintptr) int32
+	}{channelOutput})),
+	FseekProc: *(*uintptr)(unsafe.Pointer(&struct {
+		f func(tls *libc.TLS, instanceData tcl.ClientData, offset int64, mode int32, errorCodePtr uintptr) int32
+	}{channelSeek})),
+	FsetOptionProc: *(*uintptr)(unsafe.Pointer(&struct {
+		f func(tls *libc.TLS, instanceData tcl.ClientData, interp, optionName, newValue uintptr) int32
+	}{channelSetOption})),
+	FgetOptionProc: *(*uintptr)(unsafe.Pointer(&struct {
+		f func(tls *libc.TLS, instanceData tcl.ClientData, interp, optionName, dsPtr uintptr) int32
+	}{channelGetOption})),
+	FwatchProc: *(*uintptr)(unsafe.Pointer(&struct {
+		f func(tls *libc.TLS, instanceData tcl.ClientData, mask int32)
+	}{channelWatch})),
+	Fclose2Proc: *(*uintptr)(unsafe.Pointer(&struct {
+		f func(tls *libc.TLS, instanceData tcl.ClientData, interp uintptr, flags int32) int32
+	}{channelClose2})),
+	FblockModeProc: *(*uintptr)(unsafe.Pointer(&struct {
+		f func(tls *libc.TLS, instanceData tcl.ClientData, mode int32) int32
+	}{channelBlockMode})),
+	FwideSeekProc: *(*uintptr)(unsafe.Pointer(&struct {
+		f func(tls *libc.TLS, instanceData tcl.ClientData, offset tcl.Tcl_WideInt, mode int32, errorCodePtr uintptr) tcl.Tcl_WideInt
+	}{channelWideSeek})),
+}
+
+// The closeProc field contains the address of a function called by the generic
+// layer to clean up driver-related information when the channel is closed.
+// CloseProc must match the following prototype:
+//
+// The instanceData argument is the same as the value provided to
+// Tcl_CreateChannel when the channel was created. The function should release
+// any storage maintained by the channel driver for this channel, and close the
+// input and output devices encapsulated by this channel. All queued output
+// will have been flushed to the device before this function is called, and no
+// further driver operations will be invoked on this instance after calling the
+// closeProc. If the close operation is successful, the procedure should return
+// zero; otherwise it should return a nonzero POSIX error code. In addition, if
+// an error occurs and interp is not NULL, the procedure should store an error
+// message in the interpreter's result.
+func channelClose(tls *libc.TLS, instanceData tcl.ClientData, interp uintptr) int32 {
+	ch := goChannelObject(instanceData)
+	removeObject(instanceData)
+	ch.mu.Lock()
+	if ch.quit != nil {
+		close(ch.quit)
+	}
+	if ch.n != nil {
+		delete(ch.n.channels, instanceData)
+	}
+	ch.mu.Unlock()
+	if ch.c == nil {
+		return 0
+	}
+
+	if err := ch.c.Close(); err != nil {
+		if interp != 0 {
+			tcl.XTcl_SetObjResult(tls, interp, newStringObj(tls, err.Error()))
+		}
+		return channelErrno(err)
+	}
+
+	return 0
+}
+
+// The close2Proc field contains the address of a function called by the
+// generic layer to implement the half-close of bidirectional channels, ie. the
+// flags argument is TCL_CLOSE_READ or TCL_CLOSE_WRITE. Close2Proc must match
+// the following prototype:
+//
+// The return value and the meaning of interp are the same as for closeProc.
+func channelClose2(tls *libc.TLS, instanceData tcl.ClientData, interp uintptr, flags int32) int32 {
+	ch := goChannelObject(instanceData)
+	var err error
+	switch flags {
+	case 0:
+		return channelClose(tls, instanceData, interp)
+	case tcl.TCL_CLOSE_READ:
+		if x, ok := ch.r.(interface{ CloseRead() error }); ok {
+			err = x.CloseRead()
+		}
+	case tcl.TCL_CLOSE_WRITE:
+		if x, ok := ch.w.(interface{ CloseWrite() error }); ok {
+			err = x.CloseWrite()
+		}
+	}
+	if err != nil {
+		if interp != 0 {
+			tcl.XTcl_SetObjResult(tls, interp, newStringObj(tls, err.Error()))
+		}
+		return channelErrno(err)
+	}
+
+	return 0
+}
+
+// The inputProc field contains the address of a function called by the generic
+// layer to read data from the file or device and store it in an internal
+// buffer. InputProc must match the following prototype:
+//
+// InstanceData is the same as the value passed to Tcl_CreateChannel when the
+// channel was created. The buf argument points to an array of bytes in which
+// to store input from the device, and the bufSize argument indicates how many
+// bytes are available at buf.
+//
+// The errorCodePtr argument points to an integer variable provided by the
+// generic layer. If an error occurs, the function should set the variable to a
+// POSIX error code that identifies the error that occurred.
+//
+// The function should read data from the input device encapsulated by the
+// channel and store it at buf. On success, the function should return a
+// nonnegative integer indicating how many bytes were read from the input
+// device and stored at buf. On error, the function should return -1. If an
+// error occurs after some data has been read from the device, that data is
+// lost.
+//
+// If inputProc can determine that the input device has some data available but
+// less than requested by the bufSize argument, the function should only
+// attempt to read as much data as is available and return without blocking. If
+// the input device has no data available whatsoever and the channel is in
+// nonblocking mode, the function should return an EAGAIN error. If the input
+// device has no data available whatsoever and the channel is in blocking mode,
+// the function should block for the shortest possible time until at least one
+// byte of data can be read from the device; then, it should return as much
+// data as it can read without blocking.
+//
+// This value can be retrieved with Tcl_ChannelInputProc, which returns a
+// pointer to the function.
+func channelInput(tls *libc.TLS, instanceData tcl.ClientData, buf uintptr, toRead int32, errorCodePtr uintptr) int32 {
+	if buf == 0 || toRead == 0 {
+		return 0
+	}
+
+	n, err := goChannelObject(instanceData).read((*libc.RawMem)(unsafe.Pointer(buf))[:toRead:toRead])
+	if n != 0 {
+		return int32(n)
+	}
+
+	if err != nil && err != io.EOF {
+		setErrorCode(errorCodePtr, channelErrno(err))
+		return -1
+	}
+
+	return 0
+}
+
+// read reads from the channel reader, directly or, once the channel was put in
+// nonblocking mode, using the reader goroutine.
+func (ch *goChannel) read(b []byte) (int, error) {
+	ch.mu.Lock()
+	pump, nonBlocking := ch.pump, ch.nonBlocking
+	ch.mu.Unlock()
+	if pump == nil {
+		for {
+			if n, err := ch.r.Read(b); n != 0 || err != nil {
+				return n, err
+			}
+		}
+	}
+
+	if len(ch.pending) == 0 && ch.pendingErr == nil {
+		var c goChannelChunk
+		if nonBlocking {
+			select {
+			case c = <-pump:
+			default:
+				return 0, os.ErrDeadlineExceeded
+			}
+		} else {
+			c = <-pump
+		}
+		ch.pending, ch.pendingErr = c.b, c.err
+	}
+	n := copy(b, ch.pending)
+	ch.pending = ch.pending[n:]
+	if n != 0 {
+		return n, nil
+	}
+
+	return 0, ch.pendingErr
+}
+
+// startPump starts the goroutine reading from the channel reader. The
+// goroutine exits when the reader returns an error or, after the channel is
+// closed, when the pending read returns.
+func (ch *goChannel) startPump() {
+	if ch.pump != nil || ch.r == nil {
+		return
+	}
+
+	// The buffered chunk makes the channel readable, see ready.
+	pump := make(chan goChannelChunk, 1)
+	quit := make(chan struct{})
+	ch.pump = pump
+	ch.quit = quit
+	go func() {
+		for {
+			b := make([]byte, 4096)
+			n, err := ch.r.Read(b)
+			if n == 0 && err == nil {
+				continue
+			}
+
+			select {
+			case pump <- goChannelChunk{b[:n], err}:
+			case <-quit:
+				return
+			}
+			ch.mu.Lock()
+			if ch.n != nil && ch.watch&Readable != 0 {
+				ch.n.alert()
+			}
+			ch.mu.Unlock()
+			if err != nil {
+				return
+			}
+		}
+	}()
+}
+
+// The outputProc field contains the address of a function called by the
+// generic layer to transfer data from an internal buffer to the output device.
+// OutputProc must match the following prototype:
+//
+// InstanceData is the same as the value passed to Tcl_CreateChannel when the
+// channel was created. The buf argument contains an array of bytes to be
+// written to the device, and the toWrite argument indicates how many bytes are
+// to be written from the buf argument.
+//
+// The errorCodePtr argument points to an integer variable provided by the
+// generic layer. If an error occurs, the function should set this variable to
+// a POSIX error code that identifies the error.
+//
+// The function should write the data at buf to the output device encapsulated
+// by the channel. On success, the function should return a nonnegative integer
+// indicating how many bytes were written to the output device. The return
+// value is normally the same as toWrite, but may be less in some cases such as
+// if the output operation is interrupted by a signal. If an error occurs the
+// function should return -1.
+func channelOutput(tls *libc.TLS, instanceData tcl.ClientData, buf uintptr, toWrite int32, errorCodePtr uintptr) int32 {
+	if toWrite == 0 {
+		return 0
+	}
+
+	n, err := goChannelObject(instanceData).w.Write((*libc.RawMem)(unsafe.Pointer(buf))[:toWrite:toWrite])
+	if err != nil && n == 0 {
+		setErrorCode(errorCodePtr, channelErrno(err))
+		return -1
+	}
+
+	return int32(n)
+}
+
+// The blockModeProc field contains the address of a function called by the
+// generic layer to set blocking and nonblocking mode on the device.
+// BlockModeProc should match the following prototype:
+//
+// The instanceData is the same as the value passed to Tcl_CreateChannel when
+// this channel was created. The mode argument is either TCL_MODE_BLOCKING or
+// TCL_MODE_NONBLOCKING to set the device into blocking or nonblocking mode.
+// The function should return zero if the operation was successful, or a
+// nonzero POSIX error code if the operation failed.
+func channelBlockMode(tls *libc.TLS, instanceData tcl.ClientData, mode int32) int32 {
+	ch := goChannelObject(instanceData)
+	ch.mu.Lock()
+
+	defer ch.mu.Unlock()
+
+	ch.nonBlocking = mode == tcl.TCL_MODE_NONBLOCKING
+	if ch.nonBlocking {
+		ch.startPump()
+	}
+	return 0
+}
+
+// The setOptionProc field contains the address of a function called by the
+// generic layer to set a channel type specific option on a channel.
+// setOptionProc must match the following prototype:
+//
+// OptionName is the name of an option to set, and newValue is the new value
+// for that option, as a string. The instanceData is the same as the value
+// given to Tcl_CreateChannel when this channel was created. The function should
+// do whatever channel type specific action is required to implement the new
+// value of the option.
+//
+// If the option value is successfully modified to the new value, the function
+// returns TCL_OK. It should call Tcl_BadChannelOption which itself returns
+// TCL_ERROR if the optionName is unrecognized. If newValue specifies a value
+// for the option that is not supported or if a system call error occurs, the
+// function should leave an error message in the result field of interp if
+// interp is not NULL. The function should also call Tcl_SetErrno to store an
+// appropriate POSIX error code.
+func channelSetOption(tls *libc.TLS, instanceData tcl.ClientData, interp, optionName, newValue uintptr) int32 {
+	ch := goChannelObject(instanceData)
+	name := libc.GoString(optionName)
+	if !ch.hasOption(name) {
+		return ch.badOption(tls, interp, optionName)
+	}
+
+	if err := ch.opts.SetChannelOption(name, libc.GoString(newValue)); err != nil {
+		if interp != 0 {
+			tcl.XTcl_SetObjResult(tls, interp, newStringObj(tls, err.Error()))
+		}
+		tcl.XTcl_SetErrno(tls, errno.EINVAL)
+		return tcl.TCL_ERROR
+	}
+
+	return tcl.TCL_OK
+}
+
+// The getOptionProc field contains the address of a function called by the
+// generic layer to get the value of a channel type specific option on a
+// channel. getOptionProc must match the following prototype:
+//
+// OptionName is the name of an option supported by this type of channel. If
+// the option name is not NULL, the function stores its current value, as a
+// string, in the Tcl dynamic string dsPtr. If optionName is NULL, the function
+// stores in dsPtr an alternating list of all supported options and their
+// current values. On success, the function returns TCL_OK. It should call
+// Tcl_BadChannelOption which itself returns TCL_ERROR if the optionName is
+// unrecognized. If a system call error occurs, the function should leave an
+// error message in the result of interp if interp is not NULL. The function
+// should also call Tcl_SetErrno to store an appropriate POSIX error code.
+func channelGetOption(tls *libc.TLS, instanceData tcl.ClientData, interp, optionName, dsPtr uintptr) int32 {
+	ch := goChannelObject(instanceData)
+	var names []string
+	switch {
+	case optionName == 0:
+		if ch.opts != nil {
+			names = ch.opts.ChannelOptions()
+		}
+	default:
+		name := libc.GoString(optionName)
+		if !ch.hasOption(name) {
+			return ch.badOption(tls, interp, optionName)
+		}
+
+		names = []string{name}
+	}
+	for _, name := range names {
+		value, err := ch.opts.GetChannelOption(name)
+		if err != nil {
+			if interp != 0 {
+				tcl.XTcl_SetObjResult(tls, interp, newStringObj(tls, err.Error()))
+			}
+			tcl.XTcl_SetErrno(tls, errno.EINVAL)
+			return tcl.TCL_ERROR
+		}
+
+		if optionName == 0 {
+			appendElement(tls, dsPtr, name)
+		}
+		appendElement(tls, dsPtr, value)
+	}
+	return tcl.TCL_OK
+}
+
+func appendElement(tls *libc.TLS, dsPtr uintptr, s string) {
+	cs, err := libc.CString(s)
+	if err != nil {
+		panic(todo("", err))
+	}
+
+	tcl.XTcl_DStringAppendElement(tls, dsPtr, cs)
+	libc.Xfree(tls, cs)
+}
+
+func (ch *goChannel) hasOption(name string) bool {
+	if ch.opts == nil {
+		return false
+	}
+
+	for _, v := range ch.opts.ChannelOptions() {
+		if v == name {
+			return true
+		}
+	}
+	return false
+}
+
+func (ch *goChannel) badOption(tls *libc.TLS, interp, optionName uintptr) int32 {
+	var a []string
+	if ch.opts != nil {
+		for _, v := range ch.opts.ChannelOptions() {
+			a = append(a, strings.TrimPrefix(v, "-"))
+		}
+	}
+	list, err := libc.CString(strings.Join(a, " "))
+	if err != nil {
+		panic(todo("", err))
+	}
+
+	defer libc.Xfree(tls, list)
+
+	return tcl.XTcl_BadChannelOption(tls, interp, optionName, list)
+}
+
+// The seekProc field contains the address of a function called by the generic
+// layer to move the access point at which subsequent input or output
+// operations will be applied. SeekProc must match the following prototype:
+//
+// The instanceData argument is the same as the value given to
+// Tcl_CreateChannel when this channel was created. Offset and seekMode have
+// the same meaning as for the Tcl_Seek procedure (described in the manual
+// entry for Tcl_OpenFileChannel).
+//
+// The errorCodePtr argument points to an integer variable provided by the
+// generic layer for returning errno values from the function. The function
+// should set this variable to a POSIX error code if an error occurs. The
+// function should store an EINVAL error code if the channel type does not
+// implement seeking.
+//
+// The return value is the new access point or -1 in case of error. If an error
+// occurred, the function should not move the access point.
+func channelSeek(tls *libc.TLS, instanceData tcl.ClientData, offset int64, mode int32, errorCodePtr uintptr) (r int32) {
+	e := int32(errno.EINVAL)
+	defer func() {
+		if r < 0 {
+			setErrorCode(errorCodePtr, e)
+		}
+	}()
+
+	if offset < mathutil.MinInt || offset > mathutil.MaxInt {
+		return -1
+	}
+
+	file := goChannelObject(instanceData).s
+	if file == nil {
+		return -1
+	}
+
+	n0, err := file.Seek(0, os.SEEK_CUR)
+	if err != nil {
+		return -1
+	}
+
+	n, err := file.Seek(offset, int(mode))
+	if err != nil {
+		return -1
+	}
+
+	if n > math.MaxInt32 {
+		e = errno.EOVERFLOW
+		file.Seek(n0, os.SEEK_SET)
+		return -1
+	}
+
+	return int32(n)
+}
+
+// If there is a non-NULL seekProc field, the wideSeekProc field may contain
+// the address of an alternative function to use which handles wide (i.e.
+// larger than 32-bit) offsets, so allowing seeks within files larger than 2GB.
+// The wideSeekProc will be called in preference to the seekProc, but both must
+// be defined if the wideSeekProc is defined. WideSeekProc must match the
+// following prototype:
+//
+// The arguments and return values mean the same thing as with seekProc above,
+// except that the type of offsets and the return type are different.
+//
+// The seekProc value can be retrieved with Tcl_ChannelSeekProc, which returns
+// a pointer to the function, and similarly the wideSeekProc can be retrieved
+// with Tcl_ChannelWideSeekProc.
+func channelWideSeek(tls *libc.TLS, instanceData tcl.ClientData, offset tcl.Tcl_WideInt, mode int32, errorCodePtr uintptr) tcl.Tcl_WideInt {
+	file := goChannelObject(instanceData).s
+	if file == nil {
+		setErrorCode(errorCodePtr, errno.EINVAL)
+		return -1
+	}
+
+	n, err := file.Seek(offset, int(mode))
+	if err != nil {
+		setErrorCode(errorCodePtr, errno.EINVAL)
+		return -1
+	}
+
+	return tcl.Tcl_WideInt(n)
+}
+
+// The watchProc field contains the address of a function called by the generic
+// layer to initialize the event notification mechanism to notice events of
+// interest on this channel. WatchProc should match the following prototype:
+//
+// The instanceData is the same as the value passed to Tcl_CreateChannel when
+// this channel was created. The mask argument is an OR-ed combination of
+// TCL_READABLE, TCL_WRITABLE and TCL_EXCEPTION; it indicates events the caller
+// is interested in noticing on this channel.
+//
+// The function should initialize device type specific mechanisms to notice
+// when an event of interest is present on the channel. When one or more of the
+// designated events occurs on the channel, the channel driver is responsible
+// for calling Tcl_NotifyChannel to inform the generic channel module. The
+// driver should take care not to starve other channel drivers or sources of
+// callbacks by invoking Tcl_NotifyChannel too frequently. Fairness can be
+// insured by using the Tcl event queue to allow the channel event to be
+// scheduled in sequence with other events. See the description of
+// Tcl_QueueEvent for details on how to queue an event.
+//
+// This value can be retrieved with Tcl_ChannelWatchProc, which returns a
+// pointer to the function.
+func channelWatch(tls *libc.TLS, instanceData tcl.ClientData, mask int32) {
+	ch := goChannelObject(instanceData)
+	n := currentNotifier(tls)
+	ch.mu.Lock()
+
+	defer ch.mu.Unlock()
+
+	ch.n = n
+	ch.watch = mask
+	if mask == 0 {
+		delete(n.channels, instanceData)
+		return
+	}
+
+	if mask&Readable != 0 {
+		// Reading ahead is how a Go reader reports readability.
+		ch.startPump()
+	}
+	if !n.channelSource {
+		tcl.XTcl_CreateEventSource(tls, channelSetupP, channelCheckP, 0)
+		n.channelSource = true
+	}
+	n.channels[instanceData] = ch
+}
+
+// ready returns the events of interest present on the channel. Writers are
+// always writable, readers are readable once the reader goroutine has read
+// ahead.
+func (ch *goChannel) ready() int32 {
+	ch.mu.Lock()
+
+	defer ch.mu.Unlock()
+
+	mask := ch.watch & Writable
+	if ch.watch&Readable != 0 && (len(ch.pending) != 0 || ch.pendingErr != nil || len(ch.pump) != 0) {
+		mask |= Readable
+	}
+	return mask
+}
+
+// channelSetup is the setupProc of the event source of the watched channels.
+func channelSetup(tls *libc.TLS, clientData uintptr, flags int32) {
+	if flags&FileEvents == 0 {
+		return
+	}
+
+	for _, ch := range currentNotifier(tls).channels {
+		if ch.ready() != 0 {
+			sz := int(unsafe.Sizeof(tcl.Tcl_Time{}))
+			p := tls.Alloc(sz)
+			setTime(p, sz, 0, 0)
+			tcl.XTcl_SetMaxBlockTime(tls, p)
+			tls.Free(sz)
+			return
+		}
+	}
+}
+
+// channelCheck is the checkProc of the event source of the watched channels.
+// It queues a channel event for every ready channel.
+func channelCheck(tls *libc.TLS, clientData uintptr, flags int32) {
+	if flags&FileEvents == 0 {
+		return
+	}
+
+	for h, ch := range currentNotifier(tls).channels {
+		if !ch.queued && ch.ready() != 0 {
+			queueEvent(tls, channelEventP, h)
+			ch.queued = true
+		}
+	}
+}
+
+// channelEvent calls Tcl_NotifyChannel for a channel event queued by
+// channelCheck.
+func channelEvent(tls *libc.TLS, evPtr uintptr, flags int32) int32 {
+	if flags&FileEvents == 0 {
+		return 0
+	}
+
+	ch := currentNotifier(tls).channels[eventData(evPtr)]
+	if ch == nil { // The channel was closed or is no longer watched.
+		return 1
+	}
+
+	ch.queued = false
+	if mask := ch.ready(); mask != 0 {
+		tcl.XTcl_NotifyChannel(tls, ch.h, mask)
+	}
+	return 1
+}
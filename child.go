@@ -0,0 +1,220 @@
+// Copyright 2026 The Tcl Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tcl // import "modernc.org/tcl"
+
+import (
+	"fmt"
+	"strings"
+	"unsafe"
+
+	"modernc.org/libc"
+	"modernc.org/tcl/lib"
+)
+
+var childDeletedP = *(*uintptr)(unsafe.Pointer(&struct {
+	f func(tls *libc.TLS, clientData, interp uintptr)
+}{childDeleted}))
+
+func childDeleted(tls *libc.TLS, clientData, interp uintptr) {
+	child := getObject(clientData).(*Interp)
+	removeObject(clientData)
+	a := child.aliases
+	child.aliases = nil
+	// A parent being deleted removes its alias targets by itself.
+	if p := child.parent.interp; p != 0 && tcl.XTcl_InterpDeleted(tls, p) == 0 {
+		for _, cmd := range a {
+			tcl.XTcl_DeleteCommandFromToken(tls, p, cmd)
+		}
+	}
+	child.interp = 0
+}
+
+// NewChild returns a newly created child interpreter of in or an error, if
+// any. Name is the path of the child as used by the interp command. If safe
+// is true, the child is a safe interpreter, see MakeSafe.
+//
+// The child shares the thread local storage of its parent and must be used
+// only by the goroutine using the parent. The child of a bound interpreter is
+// bound to the goroutine of its parent. Closing the parent, or deleting the
+// child by a script, closes the child as well.
+func (in *Interp) NewChild(name string, safe bool) (r *Interp, err error) {
+	err = in.do(func() error { r, err = in.newChild(name, safe); return err })
+	return r, err
+}
+
+func (in *Interp) newChild(name string, safe bool) (*Interp, error) {
+	nm, err := libc.CString(name)
+	if err != nil {
+		return nil, err
+	}
+
+	defer libc.Xfree(in.tls, nm)
+
+	var isSafe int32
+	if safe {
+		isSafe = 1
+	}
+	h := tcl.XTcl_CreateSlave(in.tls, in.interp, nm, isSafe)
+	if h == 0 {
+		return nil, in.newError(tcl.TCL_ERROR)
+	}
+
+	child := &Interp{tls: in.tls, interp: h, parent: in, exec: in.exec}
+	tcl.XTcl_CallWhenDeleted(in.tls, h, childDeletedP, addObject(child))
+	return child, nil
+}
+
+// MustNewChild is like NewChild but panics on error.
+func (in *Interp) MustNewChild(name string, safe bool) *Interp {
+	child, err := in.NewChild(name, safe)
+	if err != nil {
+		panic(err)
+	}
+
+	return child
+}
+
+// Parent returns the parent of a child interpreter created by NewChild or nil
+// otherwise.
+func (in *Interp) Parent() *Interp { return in.parent }
+
+// Alias creates the command name in the child interpreter in. Invoking the
+// command executes target in the context of the parent interpreter, which is
+// passed to target as its *Interp argument. The first element of the target
+// args is name. The result and return code of target are transferred back to
+// the child. Like with 'interp alias', the target is a command of the parent,
+// named ::tcl::go::aliasN, and 'interp aliases' lists the alias. The target
+// is deleted with the alias or the child.
+func (in *Interp) Alias(name string, target CmdProc) error {
+	return in.do(func() error { return in.alias(name, target) })
+}
+
+func (in *Interp) alias(name string, target CmdProc) error {
+	parent := in.parent
+	if parent == nil {
+		return fmt.Errorf("not a child interpreter")
+	}
+
+	targetName := fmt.Sprintf("::tcl::go::alias%d", token())
+	nm, err := libc.CString(name)
+	if err != nil {
+		return err
+	}
+
+	defer libc.Xfree(in.tls, nm)
+
+	tnm, err := libc.CString(targetName)
+	if err != nil {
+		return err
+	}
+
+	defer libc.Xfree(in.tls, tnm)
+
+	var cmd *Command
+	cmd, err = parent.newCommand(
+		targetName,
+		func(clientData interface{}, in *Interp, args []string) int {
+			args[0] = name
+			return target(clientData, in, args)
+		},
+		nil,
+		func(clientData interface{}) {
+			for i, v := range in.aliases {
+				if v == cmd.cmd {
+					in.aliases = append(in.aliases[:i], in.aliases[i+1:]...)
+					break
+				}
+			}
+		},
+	)
+	if err != nil {
+		return err
+	}
+
+	in.aliases = append(in.aliases, cmd.cmd)
+	if rc := tcl.XTcl_CreateAlias(in.tls, in.interp, nm, parent.interp, tnm, 0, 0); rc != tcl.TCL_OK {
+		err := in.newError(rc)
+		tcl.XTcl_DeleteCommandFromToken(in.tls, parent.interp, cmd.cmd)
+		return err
+	}
+
+	return nil
+}
+
+// MustAlias is like Alias but panics on error.
+func (in *Interp) MustAlias(name string, target CmdProc) {
+	if err := in.Alias(name, target); err != nil {
+		panic(err)
+	}
+}
+
+// Hide hides the global command name. Hidden commands cannot be invoked by
+// scripts evaluated in the interpreter, but they can be exposed again using
+// Expose or invoked by the parent interpreter using 'interp invokehidden'.
+func (in *Interp) Hide(name string) error {
+	return in.do(func() error { return in.hide(name) })
+}
+
+func (in *Interp) hide(name string) error {
+	nm, err := libc.CString(name)
+	if err != nil {
+		return err
+	}
+
+	defer libc.Xfree(in.tls, nm)
+
+	hnm, err := libc.CString(strings.TrimPrefix(name, "::"))
+	if err != nil {
+		return err
+	}
+
+	defer libc.Xfree(in.tls, hnm)
+
+	if rc := tcl.XTcl_HideCommand(in.tls, in.interp, nm, hnm); rc != tcl.TCL_OK {
+		return in.newError(rc)
+	}
+
+	return nil
+}
+
+// Expose makes the hidden command name invocable again.
+func (in *Interp) Expose(name string) error {
+	return in.do(func() error { return in.expose(name) })
+}
+
+func (in *Interp) expose(name string) error {
+	hnm, err := libc.CString(strings.TrimPrefix(name, "::"))
+	if err != nil {
+		return err
+	}
+
+	defer libc.Xfree(in.tls, hnm)
+
+	if rc := tcl.XTcl_ExposeCommand(in.tls, in.interp, hnm, hnm); rc != tcl.TCL_OK {
+		return in.newError(rc)
+	}
+
+	return nil
+}
+
+// MakeSafe turns in into a safe interpreter by hiding all commands and
+// variables that could harm the host, like exec, open or socket.
+func (in *Interp) MakeSafe() error {
+	return in.do(in.makeSafe)
+}
+
+func (in *Interp) makeSafe() error {
+	if rc := tcl.XTcl_MakeSafe(in.tls, in.interp); rc != tcl.TCL_OK {
+		return in.newError(rc)
+	}
+
+	return nil
+}
+
+// IsSafe reports whether in is a safe interpreter.
+func (in *Interp) IsSafe() (r bool, err error) {
+	err = in.do(func() error { r = tcl.XTcl_IsSafe(in.tls, in.interp) != 0; return nil })
+	return r, err
+}
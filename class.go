@@ -0,0 +1,199 @@
+// Copyright 2026 The Tcl Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tcl // import "modernc.org/tcl"
+
+import (
+	"fmt"
+	"reflect"
+	"runtime"
+	"unsafe"
+
+	"modernc.org/libc"
+	"modernc.org/tcl/lib"
+)
+
+const (
+	goMethodName         = "gomethod"
+	goValueName          = "govalue"
+	tclOOMetadataVersion = 1
+	tclOOMethodVersion   = 1
+	tclOOPublicMethod    = 1
+)
+
+var (
+	_             = copy(cGoMethodName[:], goMethodName)
+	_             = copy(cGoValueName[:], goValueName)
+	cGoMethodName [len(goMethodName) + 1]byte
+	cGoValueName  [len(goValueName) + 1]byte
+)
+
+var goMethodType = tcl.Tcl_MethodType{
+	Fversion: tclOOMethodVersion,
+	Fname:    uintptr(unsafe.Pointer(&cGoMethodName[0])),
+	FcallProc: *(*uintptr)(unsafe.Pointer(&struct {
+		f func(tls *libc.TLS, clientData, interp, objectContext uintptr, objc int32, objv uintptr) int32
+	}{goMethodCall})),
+	FdeleteProc: *(*uintptr)(unsafe.Pointer(&struct {
+		f func(tls *libc.TLS, clientData uintptr)
+	}{removeHandle})),
+	FcloneProc: *(*uintptr)(unsafe.Pointer(&struct {
+		f func(tls *libc.TLS, interp, oldClientData, newClientData uintptr) int32
+	}{cloneHandle})),
+}
+
+var goValueType = tcl.Tcl_ObjectMetadataType{
+	Fversion: tclOOMetadataVersion,
+	Fname:    uintptr(unsafe.Pointer(&cGoValueName[0])),
+	FdeleteProc: *(*uintptr)(unsafe.Pointer(&struct {
+		f func(tls *libc.TLS, clientData uintptr)
+	}{removeHandle})),
+	FcloneProc: *(*uintptr)(unsafe.Pointer(&struct {
+		f func(tls *libc.TLS, interp, oldClientData, newClientData uintptr) int32
+	}{goValueClone})),
+}
+
+// goClass is the client data of the constructor of a class defined by
+// DefineClass.
+type goClass struct {
+	in    *Interp
+	proto reflect.Value
+}
+
+// goMethod is the client data of a method of a class defined by DefineClass.
+type goMethod struct {
+	in    *Interp
+	index int
+}
+
+// DefineClass creates the TclOO class name whose instances wrap Go values of
+// the type of proto and returns an error, if any. Relative names are
+// resolved in the current namespace.
+//
+// The constructor of the class, which takes no arguments, creates a new Go
+// value initialized to a copy of proto, or of the value proto points to if it
+// is a pointer. Every exported method of the type, including methods with a
+// pointer receiver, becomes a public method of the class with the same name.
+// The method arguments and results are converted like the arguments and
+// results of a function registered by RegisterFunc. The Go value is released
+// when the object is destroyed. Copying an object with oo::copy copies the Go
+// value.
+//
+// For example
+//
+//	type Counter struct{ N int }
+//
+//	func (c *Counter) Inc(n int) int { c.N += n; return c.N }
+//
+//	...
+//	in.DefineClass("Counter", Counter{})
+//
+// enables scripts like
+//
+//	set c [Counter new]
+//	$c Inc 5
+func (in *Interp) DefineClass(name string, proto interface{}) error {
+	return in.do(func() error { return in.defineClass(name, proto) })
+}
+
+func (in *Interp) defineClass(name string, proto interface{}) error {
+	v := reflect.ValueOf(proto)
+	if !v.IsValid() {
+		return fmt.Errorf("invalid prototype: %v", proto)
+	}
+
+	if v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			v = reflect.Zero(v.Type().Elem())
+		} else {
+			v = v.Elem()
+		}
+	}
+	rc := in.evalObjv([]*Obj{NewStringObj("::oo::class"), NewStringObj("create"), NewStringObj(name)}, 0)
+	if rc != tcl.TCL_OK {
+		return in.newError(rc)
+	}
+
+	obj := tcl.XTcl_GetObjectFromObj(in.tls, in.interp, tcl.XTcl_GetObjResult(in.tls, in.interp))
+	if obj == 0 {
+		return in.newError(tcl.TCL_ERROR)
+	}
+
+	cls := tcl.XTcl_GetObjectAsClass(in.tls, obj)
+	ctor := tcl.XTcl_NewMethod(in.tls, in.interp, cls, 0, tclOOPublicMethod, uintptr(unsafe.Pointer(&goMethodType)), addObject(&goClass{in, v}))
+	tcl.XTcl_ClassSetConstructor(in.tls, in.interp, cls, ctor)
+	t := reflect.PtrTo(v.Type())
+	for i := 0; i < t.NumMethod(); i++ {
+		nm := NewStringObj(t.Method(i).Name)
+		tcl.XTcl_NewMethod(in.tls, in.interp, cls, nm.p, tclOOPublicMethod, uintptr(unsafe.Pointer(&goMethodType)), addObject(&goMethod{in, i}))
+		runtime.KeepAlive(nm)
+	}
+	tcl.XTcl_ResetResult(in.tls, in.interp)
+	return nil
+}
+
+// MustDefineClass is like DefineClass but panics on error.
+func (in *Interp) MustDefineClass(name string, proto interface{}) {
+	if err := in.DefineClass(name, proto); err != nil {
+		panic(err)
+	}
+}
+
+// goMethodCall implements the constructor and the methods of a class defined
+// by DefineClass.
+func goMethodCall(tls *libc.TLS, clientData, interp, objectContext uintptr, objc int32, objv uintptr) int32 {
+	object := tcl.XTcl_ObjectContextObject(tls, objectContext)
+	skip := tcl.XTcl_ObjectContextSkippedArgs(tls, objectContext)
+	var in *Interp
+	switch x := getObject(clientData).(type) {
+	case *goClass:
+		in = x.in
+	case *goMethod:
+		in = x.in
+	}
+	args := make([]*Obj, objc)
+	for i := range args {
+		args[i] = in.newObj(*(*uintptr)(unsafe.Pointer(objv + uintptr(i)*unsafe.Sizeof(uintptr(0)))))
+	}
+	switch x := getObject(clientData).(type) {
+	case *goClass:
+		if objc != skip {
+			return x.in.wrongNumArgs(args[:skip], "")
+		}
+
+		v := reflect.New(x.proto.Type())
+		v.Elem().Set(x.proto)
+		tcl.XTcl_ObjectSetMetadata(tls, object, uintptr(unsafe.Pointer(&goValueType)), addObject(v))
+		return tcl.TCL_OK
+	case *goMethod:
+		h := tcl.XTcl_ObjectGetMetadata(tls, object, uintptr(unsafe.Pointer(&goValueType)))
+		if h == 0 {
+			return x.in.setError(fmt.Errorf("object has no Go value, was the constructor of its class called?"))
+		}
+
+		v := getObject(h).(reflect.Value)
+		return x.in.callFunc(v.Method(x.index), args, int(skip))
+	default:
+		panic(todo("%T", x))
+	}
+}
+
+// goValueClone copies the Go value of an object copied by oo::copy.
+func goValueClone(tls *libc.TLS, interp, oldClientData, newClientData uintptr) int32 {
+	v := getObject(oldClientData).(reflect.Value)
+	w := reflect.New(v.Type().Elem())
+	w.Elem().Set(v.Elem())
+	*(*uintptr)(unsafe.Pointer(newClientData)) = addObject(w)
+	return tcl.TCL_OK
+}
+
+// cloneHandle shares the object registered under oldClientData with a copy
+// of a method made by oo::copy.
+func cloneHandle(tls *libc.TLS, interp, oldClientData, newClientData uintptr) int32 {
+	*(*uintptr)(unsafe.Pointer(newClientData)) = addObject(getObject(oldClientData))
+	return tcl.TCL_OK
+}
+
+// removeHandle removes the object registered under clientData.
+func removeHandle(tls *libc.TLS, clientData uintptr) { removeObject(clientData) }
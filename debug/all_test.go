@@ -0,0 +1,345 @@
+// Copyright 2026 The Tcl Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package debug // import "modernc.org/tcl/debug"
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/textproto"
+	"reflect"
+	"strconv"
+	"testing"
+
+	"modernc.org/tcl"
+)
+
+const testScript = `proc add {a b} {
+	set c [expr {$a+$b}]
+	return $c
+}
+set x 1
+set y [add $x 2]
+set z done
+`
+
+func newInterp(t *testing.T) *tcl.Interp {
+	in, err := tcl.NewInterp()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() {
+		if err := in.Close(); err != nil {
+			t.Error(err)
+		}
+	})
+	return in
+}
+
+func TestDebugger(t *testing.T) {
+	in := newInterp(t)
+	d, err := New(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer d.Close()
+
+	d.SetBreakpoint("dbg.tcl", 2)
+	type stop struct {
+		Reason string
+		Line   int
+		Level  int
+	}
+	var stops []stop
+	actions := []Action{StepOver, StepOut, Continue}
+	d.SetStopHandler(func(s *Stop) Action {
+		stops = append(stops, stop{s.Reason, s.Line, s.Level})
+		if len(stops) == 1 {
+			if g, e := s.File, normalize("dbg.tcl"); g != e {
+				t.Errorf("got %q exp %q", g, e)
+			}
+
+			stack, err := s.Stack()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if g, e := len(stack), 2; g != e {
+				t.Fatalf("got %v exp %v: %+v", g, e, stack)
+			}
+
+			if g, e := fmt.Sprint(stack[0].Level, stack[0].Proc, stack[0].Line), "1 ::add 2"; g != e {
+				t.Errorf("got %q exp %q", g, e)
+			}
+
+			if g, e := fmt.Sprint(stack[1].Level, stack[1].Line), "0 6"; g != e {
+				t.Errorf("got %q exp %q", g, e)
+			}
+
+			vars, err := s.Variables(1)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if g, e := vars, []Variable{{Name: "a", Value: "1"}, {Name: "b", Value: "2"}}; !reflect.DeepEqual(g, e) {
+				t.Errorf("got %+v exp %+v", g, e)
+			}
+
+			r, err := s.Eval(1, "expr {$a*10}")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if g, e := r, "10"; g != e {
+				t.Errorf("got %q exp %q", g, e)
+			}
+		}
+		a := actions[0]
+		actions = actions[1:]
+		return a
+	})
+	if _, err := in.EvalNamed(testScript, "dbg.tcl"); err != nil {
+		t.Fatal(err)
+	}
+
+	if g, e := stops, []stop{{"breakpoint", 2, 1}, {"step", 3, 1}, {"step", 6, 0}}; !reflect.DeepEqual(g, e) {
+		t.Errorf("got %+v exp %+v", g, e)
+	}
+
+	if g, e := in.MustEval("set y"), "3"; g != e {
+		t.Errorf("got %q exp %q", g, e)
+	}
+
+	d.ClearBreakpoint("dbg.tcl", 2)
+	d.SetBreakpoint("dbg.tcl", 7)
+	d.SetStopHandler(func(s *Stop) Action {
+		if _, err := s.Eval(0, "set x 42"); err != nil {
+			t.Error(err)
+		}
+		return Continue
+	})
+	r, err := in.EvalNamed(testScript, "dbg.tcl")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if g, e := r, "done"; g != e {
+		t.Errorf("got %q exp %q", g, e)
+	}
+
+	if g, e := in.MustEval("set x"), "42"; g != e {
+		t.Errorf("got %q exp %q", g, e)
+	}
+}
+
+// dapClient is a minimal DAP client used by TestServeDAP.
+type dapClient struct {
+	conn net.Conn
+	r    *bufio.Reader
+	seq  int
+}
+
+func (c *dapClient) read() (*dapMessage, error) {
+	h, err := textproto.NewReader(c.r).ReadMIMEHeader()
+	if err != nil {
+		return nil, err
+	}
+
+	n, err := strconv.Atoi(h.Get("Content-Length"))
+	if err != nil {
+		return nil, err
+	}
+
+	b := make([]byte, n)
+	if _, err := io.ReadFull(c.r, b); err != nil {
+		return nil, err
+	}
+
+	var m dapMessage
+	if err := json.Unmarshal(b, &m); err != nil {
+		return nil, err
+	}
+
+	return &m, nil
+}
+
+// request sends a request and returns its response body.
+func (c *dapClient) request(command string, args interface{}) (map[string]interface{}, error) {
+	c.seq++
+	b, err := json.Marshal(map[string]interface{}{"seq": c.seq, "type": "request", "command": command, "arguments": args})
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := fmt.Fprintf(c.conn, "Content-Length: %d\r\n\r\n%s", len(b), b); err != nil {
+		return nil, err
+	}
+
+	for {
+		m, err := c.read()
+		if err != nil {
+			return nil, err
+		}
+
+		if m.Type != "response" || m.RequestSeq != c.seq {
+			continue
+		}
+
+		if m.Success == nil || !*m.Success {
+			return nil, fmt.Errorf("%s: %s", command, m.Message)
+		}
+
+		body, _ := m.Body.(map[string]interface{})
+		return body, nil
+	}
+}
+
+// event waits for the event named event and returns its body.
+func (c *dapClient) event(event string) (map[string]interface{}, error) {
+	for {
+		m, err := c.read()
+		if err != nil {
+			return nil, err
+		}
+
+		if m.Type == "event" && m.Event == event {
+			body, _ := m.Body.(map[string]interface{})
+			return body, nil
+		}
+	}
+}
+
+func (c *dapClient) session() (err error) {
+	if _, err = c.request("initialize", map[string]interface{}{"adapterID": "test"}); err != nil {
+		return err
+	}
+
+	if _, err = c.event("initialized"); err != nil {
+		return err
+	}
+
+	if _, err = c.request("launch", nil); err != nil {
+		return err
+	}
+
+	body, err := c.request("setBreakpoints", map[string]interface{}{
+		"source":      map[string]interface{}{"path": "dbg.tcl"},
+		"breakpoints": []map[string]interface{}{{"line": 2}},
+	})
+	if err != nil {
+		return err
+	}
+
+	if g, e := fmt.Sprint(body["breakpoints"]), "[map[line:2 verified:true]]"; g != e {
+		return fmt.Errorf("got %q exp %q", g, e)
+	}
+
+	if _, err = c.request("configurationDone", nil); err != nil {
+		return err
+	}
+
+	if body, err = c.event("stopped"); err != nil {
+		return err
+	}
+
+	if g, e := body["reason"], "breakpoint"; g != e {
+		return fmt.Errorf("got %q exp %q", g, e)
+	}
+
+	if body, err = c.request("stackTrace", map[string]interface{}{"threadId": 1}); err != nil {
+		return err
+	}
+
+	frames, _ := body["stackFrames"].([]interface{})
+	if g, e := len(frames), 2; g != e {
+		return fmt.Errorf("got %v exp %v", g, e)
+	}
+
+	f := frames[0].(map[string]interface{})
+	if g, e := fmt.Sprint(f["id"], f["name"], f["line"]), "2 ::add 2"; g != e {
+		return fmt.Errorf("got %q exp %q", g, e)
+	}
+
+	if body, err = c.request("variables", map[string]interface{}{"variablesReference": 2}); err != nil {
+		return err
+	}
+
+	if g, e := fmt.Sprint(body["variables"]), "[map[name:a value:1 variablesReference:0] map[name:b value:2 variablesReference:0]]"; g != e {
+		return fmt.Errorf("got %q exp %q", g, e)
+	}
+
+	if body, err = c.request("evaluate", map[string]interface{}{"expression": "expr {$a+$b}", "frameId": 2}); err != nil {
+		return err
+	}
+
+	if g, e := body["result"], "3"; g != e {
+		return fmt.Errorf("got %q exp %q", g, e)
+	}
+
+	if _, err = c.request("continue", map[string]interface{}{"threadId": 1}); err != nil {
+		return err
+	}
+
+	if body, err = c.event("exited"); err != nil {
+		return err
+	}
+
+	if g, e := body["exitCode"], 0.0; g != e {
+		return fmt.Errorf("got %v exp %v", g, e)
+	}
+
+	if _, err = c.event("terminated"); err != nil {
+		return err
+	}
+
+	_, err = c.request("disconnect", nil)
+	return err
+}
+
+func TestServeDAP(t *testing.T) {
+	in := newInterp(t)
+	d, err := New(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer d.Close()
+
+	server, client := net.Pipe()
+
+	defer client.Close()
+
+	ready := make(chan struct{})
+	served := make(chan error, 1)
+	go func() { served <- d.ServeDAP(server, ready) }()
+	c := &dapClient{conn: client, r: bufio.NewReader(client)}
+	session := make(chan error, 1)
+	go func() { session <- c.session() }()
+	select {
+	case <-ready:
+	case err := <-session:
+		t.Fatalf("session ended before configuration: %v", err)
+	}
+	if _, err := in.EvalNamed(testScript, "dbg.tcl"); err != nil {
+		t.Fatal(err)
+	}
+
+	d.Terminate(0)
+	if err := <-session; err != nil {
+		t.Fatal(err)
+	}
+
+	if err := <-served; err != nil {
+		t.Fatal(err)
+	}
+
+	if g, e := in.MustEval("set y"), "3"; g != e {
+		t.Errorf("got %q exp %q", g, e)
+	}
+}
@@ -0,0 +1,401 @@
+// Copyright 2026 The Tcl Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package debug // import "modernc.org/tcl/debug"
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/textproto"
+	"path/filepath"
+	"strconv"
+	"sync"
+)
+
+// dapThread is the ID of the only thread reported to DAP clients.
+const dapThread = 1
+
+// dapMessage is a Debug Adapter Protocol request, response or event.
+type dapMessage struct {
+	Seq        int             `json:"seq"`
+	Type       string          `json:"type"`
+	Command    string          `json:"command,omitempty"`
+	Arguments  json.RawMessage `json:"arguments,omitempty"`
+	RequestSeq int             `json:"request_seq,omitempty"`
+	Success    *bool           `json:"success,omitempty"`
+	Message    string          `json:"message,omitempty"`
+	Event      string          `json:"event,omitempty"`
+	Body       interface{}     `json:"body,omitempty"`
+}
+
+// dapSession is a DAP client attached to a Debugger.
+type dapSession struct {
+	d     *Debugger
+	done  chan struct{} // Closed when the session ends.
+	ready chan<- struct{}
+	r     *bufio.Reader
+	w     io.Writer
+
+	mu   sync.Mutex // Guards seq, stop and writes to w.
+	seq  int
+	stop *Stop
+	work chan func(s *Stop) (a Action, resume bool)
+}
+
+// ServeDAP serves a Debug Adapter Protocol client connected by conn, for
+// example a net.Conn or the standard input and output of the process. It
+// returns when the client disconnects or an error, if any, occurs. If ready is
+// not nil, it is closed once the client has configured the session, ie. after
+// it has set the initial breakpoints. An interpreter stopped when the client
+// disconnects is resumed and the breakpoints are cleared. Only one client can
+// be attached to a debugger at a time.
+//
+// The supported requests are initialize, launch, attach, setBreakpoints,
+// setExceptionBreakpoints, configurationDone, threads, stackTrace, scopes,
+// variables, evaluate, continue, next, stepIn, stepOut, pause, disconnect and
+// terminate. The launch and attach requests do not start anything, they just
+// attach the client to the debugger. They accept the stopOnEntry argument.
+func (d *Debugger) ServeDAP(conn io.ReadWriter, ready chan<- struct{}) error {
+	s := &dapSession{
+		d:     d,
+		done:  make(chan struct{}),
+		ready: ready,
+		r:     bufio.NewReader(conn),
+		w:     conn,
+		work:  make(chan func(*Stop) (Action, bool)),
+	}
+	d.mu.Lock()
+	if d.session != nil {
+		d.mu.Unlock()
+		return fmt.Errorf("a DAP client is already attached")
+	}
+
+	d.session = s
+	d.mu.Unlock()
+
+	defer s.detach()
+
+	d.SetStopHandler(s.stopped)
+	for {
+		m, err := s.read()
+		if err != nil {
+			if err == io.EOF {
+				return nil
+			}
+
+			return err
+		}
+
+		if m.Type != "request" {
+			continue
+		}
+
+		if done, err := s.handle(m); done || err != nil {
+			return err
+		}
+	}
+}
+
+// Terminate reports to the attached DAP client, if any, that the debugged
+// program exited with exitCode.
+func (d *Debugger) Terminate(exitCode int) {
+	d.mu.Lock()
+	s := d.session
+	d.mu.Unlock()
+	if s == nil {
+		return
+	}
+
+	s.event("exited", map[string]interface{}{"exitCode": exitCode})
+	s.event("terminated", nil)
+}
+
+// Output returns a Writer sending the data written to it as output events of
+// category, for example "stdout" or "stderr", to the attached DAP client, if
+// any. Data written while no client is attached is discarded.
+func (d *Debugger) Output(category string) io.Writer {
+	return dapOutput{d, category}
+}
+
+type dapOutput struct {
+	d        *Debugger
+	category string
+}
+
+func (w dapOutput) Write(b []byte) (int, error) {
+	w.d.mu.Lock()
+	s := w.d.session
+	w.d.mu.Unlock()
+	if s != nil {
+		s.event("output", map[string]interface{}{"category": w.category, "output": string(b)})
+	}
+	return len(b), nil
+}
+
+// detach ends the session, which resumes a stopped interpreter.
+func (s *dapSession) detach() {
+	d := s.d
+	d.SetStopHandler(nil)
+	d.ClearBreakpoints()
+	d.mu.Lock()
+	d.session = nil
+	d.mu.Unlock()
+	close(s.done)
+}
+
+// stopped is the StopHandler of the session. It serves the requests needing
+// a stopped interpreter until one of them resumes it.
+func (s *dapSession) stopped(stop *Stop) Action {
+	s.mu.Lock()
+	s.stop = stop
+	s.mu.Unlock()
+
+	defer func() {
+		s.mu.Lock()
+		s.stop = nil
+		s.mu.Unlock()
+	}()
+
+	s.event("stopped", map[string]interface{}{
+		"reason":            stop.Reason,
+		"threadId":          dapThread,
+		"allThreadsStopped": true,
+	})
+	for {
+		select {
+		case f := <-s.work:
+			if a, resume := f(stop); resume {
+				return a
+			}
+		case <-s.done:
+			return Continue
+		}
+	}
+}
+
+// onStop executes f with the stopped interpreter. It returns an error if the
+// interpreter is not stopped.
+func (s *dapSession) onStop(f func(stop *Stop) error) error {
+	s.mu.Lock()
+	stopped := s.stop != nil
+	s.mu.Unlock()
+	if !stopped {
+		return fmt.Errorf("the interpreter is not stopped")
+	}
+
+	ch := make(chan error, 1)
+	select {
+	case s.work <- func(stop *Stop) (Action, bool) {
+		ch <- f(stop)
+		return Continue, false
+	}:
+		return <-ch
+	case <-s.done:
+		return fmt.Errorf("the interpreter is not stopped")
+	}
+}
+
+// resume resumes a stopped interpreter, if any, with a.
+func (s *dapSession) resume(a Action) {
+	s.mu.Lock()
+	stopped := s.stop != nil
+	s.mu.Unlock()
+	if stopped {
+		select {
+		case s.work <- func(*Stop) (Action, bool) { return a, true }:
+		case <-s.done:
+		}
+	}
+}
+
+func (s *dapSession) read() (*dapMessage, error) {
+	h, err := textproto.NewReader(s.r).ReadMIMEHeader()
+	if err != nil {
+		return nil, err
+	}
+
+	n, err := strconv.Atoi(h.Get("Content-Length"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid DAP message header: %v", h)
+	}
+
+	b := make([]byte, n)
+	if _, err := io.ReadFull(s.r, b); err != nil {
+		return nil, err
+	}
+
+	var m dapMessage
+	if err := json.Unmarshal(b, &m); err != nil {
+		return nil, err
+	}
+
+	return &m, nil
+}
+
+func (s *dapSession) write(m *dapMessage) error {
+	s.mu.Lock()
+
+	defer s.mu.Unlock()
+
+	s.seq++
+	m.Seq = s.seq
+	b, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+
+	if _, err := fmt.Fprintf(s.w, "Content-Length: %d\r\n\r\n", len(b)); err != nil {
+		return err
+	}
+
+	_, err = s.w.Write(b)
+	return err
+}
+
+func (s *dapSession) event(event string, body interface{}) error {
+	return s.write(&dapMessage{Type: "event", Event: event, Body: body})
+}
+
+func (s *dapSession) respond(req *dapMessage, body interface{}, err error) error {
+	ok := err == nil
+	m := &dapMessage{Type: "response", RequestSeq: req.Seq, Command: req.Command, Success: &ok, Body: body}
+	if err != nil {
+		m.Message = err.Error()
+	}
+	return s.write(m)
+}
+
+// handle serves the request m. It reports whether the session is over.
+func (s *dapSession) handle(m *dapMessage) (done bool, err error) {
+	var args struct {
+		Breakpoints []struct {
+			Line int `json:"line"`
+		} `json:"breakpoints"`
+		Expression         string                `json:"expression"`
+		FrameID            *int                  `json:"frameId"`
+		Source             struct{ Path string } `json:"source"`
+		StopOnEntry        bool                  `json:"stopOnEntry"`
+		VariablesReference int                   `json:"variablesReference"`
+	}
+	if len(m.Arguments) != 0 {
+		if err := json.Unmarshal(m.Arguments, &args); err != nil {
+			return false, s.respond(m, nil, err)
+		}
+	}
+
+	d := s.d
+	switch m.Command {
+	case "initialize":
+		if err := s.respond(m, map[string]interface{}{"supportsConfigurationDoneRequest": true}, nil); err != nil {
+			return false, err
+		}
+
+		return false, s.event("initialized", nil)
+	case "launch", "attach":
+		if args.StopOnEntry {
+			d.Pause()
+		}
+		return false, s.respond(m, nil, nil)
+	case "setBreakpoints":
+		var lines []int
+		var bps []map[string]interface{}
+		for _, v := range args.Breakpoints {
+			lines = append(lines, v.Line)
+			bps = append(bps, map[string]interface{}{"verified": true, "line": v.Line})
+		}
+		d.SetBreakpoints(args.Source.Path, lines)
+		return false, s.respond(m, map[string]interface{}{"breakpoints": bps}, nil)
+	case "setExceptionBreakpoints":
+		return false, s.respond(m, nil, nil)
+	case "configurationDone":
+		if s.ready != nil {
+			close(s.ready)
+			s.ready = nil
+		}
+		return false, s.respond(m, nil, nil)
+	case "threads":
+		return false, s.respond(m, map[string]interface{}{
+			"threads": []map[string]interface{}{{"id": dapThread, "name": "main"}},
+		}, nil)
+	case "stackTrace":
+		var frames []map[string]interface{}
+		err := s.onStop(func(stop *Stop) error {
+			stack, err := stop.Stack()
+			for _, v := range stack {
+				name := v.Proc
+				if name == "" {
+					name = v.Cmd
+				}
+				f := map[string]interface{}{
+					// Frame IDs are procedure levels plus one, they are
+					// also the variable references of the frame scopes.
+					"id":     v.Level + 1,
+					"name":   name,
+					"line":   v.Line,
+					"column": 1,
+				}
+				if v.File != "" {
+					f["source"] = map[string]interface{}{"name": filepath.Base(v.File), "path": v.File}
+				}
+				frames = append(frames, f)
+			}
+			return err
+		})
+		return false, s.respond(m, map[string]interface{}{"stackFrames": frames, "totalFrames": len(frames)}, err)
+	case "scopes":
+		if args.FrameID == nil {
+			return false, s.respond(m, nil, fmt.Errorf("missing frameId"))
+		}
+
+		name := "Locals"
+		if *args.FrameID == 1 {
+			name = "Globals"
+		}
+		return false, s.respond(m, map[string]interface{}{
+			"scopes": []map[string]interface{}{{"name": name, "variablesReference": *args.FrameID, "expensive": false}},
+		}, nil)
+	case "variables":
+		vars := []map[string]interface{}{}
+		err := s.onStop(func(stop *Stop) error {
+			a, err := stop.Variables(args.VariablesReference - 1)
+			for _, v := range a {
+				vars = append(vars, map[string]interface{}{"name": v.Name, "value": v.Value, "variablesReference": 0})
+			}
+			return err
+		})
+		return false, s.respond(m, map[string]interface{}{"variables": vars}, err)
+	case "evaluate":
+		var r string
+		err := s.onStop(func(stop *Stop) (err error) {
+			level := stop.Level
+			if args.FrameID != nil {
+				level = *args.FrameID - 1
+			}
+			r, err = stop.Eval(level, args.Expression)
+			return err
+		})
+		return false, s.respond(m, map[string]interface{}{"result": r, "variablesReference": 0}, err)
+	case "continue", "next", "stepIn", "stepOut":
+		a := map[string]Action{"continue": Continue, "next": StepOver, "stepIn": StepIn, "stepOut": StepOut}[m.Command]
+		var body interface{}
+		if a == Continue {
+			body = map[string]interface{}{"allThreadsContinued": true}
+		}
+		if err := s.respond(m, body, nil); err != nil {
+			return false, err
+		}
+
+		s.resume(a)
+		return false, nil
+	case "pause":
+		d.Pause()
+		return false, s.respond(m, nil, nil)
+	case "disconnect", "terminate":
+		return true, s.respond(m, nil, nil)
+	default:
+		return false, s.respond(m, nil, fmt.Errorf("unsupported request: %s", m.Command))
+	}
+}
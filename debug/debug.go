@@ -0,0 +1,433 @@
+// Copyright 2026 The Tcl Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package debug implements a debugger for Tcl scripts evaluated by a
+// tcl.Interp.
+//
+// A Debugger supports line breakpoints, stepping into, over and out of
+// procedures, pausing a running script and inspecting the call stack and the
+// variables of a stopped script. Debuggers can be controlled from Go using a
+// stop handler or by an editor speaking the Debug Adapter Protocol, see
+// Debugger.ServeDAP.
+//
+// The gotclsh shell accepts a -dap flag starting a DAP server before
+// evaluating a script:
+//
+//	gotclsh -dap localhost:4711 script.tcl ?arg ...?
+package debug // import "modernc.org/tcl/debug"
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+
+	"modernc.org/tcl"
+)
+
+// Action tells a stopped interpreter how to continue.
+type Action int
+
+// Values of Action.
+const (
+	Continue Action = iota // Run until the next breakpoint or pause.
+	StepIn                 // Stop at the next command on a different line.
+	StepOver               // Like StepIn but do not stop in called procedures.
+	StepOut                // Stop after the current procedure returns.
+)
+
+// String implements fmt.Stringer.
+func (a Action) String() string {
+	switch a {
+	case Continue:
+		return "continue"
+	case StepIn:
+		return "step in"
+	case StepOver:
+		return "step over"
+	case StepOut:
+		return "step out"
+	default:
+		return fmt.Sprintf("Action(%d)", int(a))
+	}
+}
+
+// StopHandler is called when the interpreter stops. It executes on the
+// goroutine evaluating the script, which remains blocked until the handler
+// returns. The returned Action determines how the evaluation continues.
+type StopHandler func(s *Stop) Action
+
+// Debugger debugs the scripts evaluated by an interpreter. Its methods, except
+// those of Stop, may be called from any goroutine.
+type Debugger struct {
+	breakpoints map[string]map[int]bool // File: lines.
+	files       map[string]string       // File name as reported by Tcl: normalized name.
+	handler     StopHandler
+	in          *tcl.Interp
+	mu          sync.Mutex
+	trace       *tcl.CmdTrace
+
+	lastFile  string // Location of the last traced command.
+	lastLine  int
+	mode      Action // Action returned by the last stop.
+	pause     int32  // Accessed atomically.
+	session   *dapSession
+	stepFile  string // Location of the last stop.
+	stepLevel int
+	stepLine  int
+}
+
+// New returns a newly created Debugger of the interpreter in. Only one
+// debugger should be attached to an interpreter at a time. The debugger
+// traces all commands executed by in, which slows down the interpreter, until
+// it is closed.
+func New(in *tcl.Interp) (*Debugger, error) {
+	d := &Debugger{
+		breakpoints: map[string]map[int]bool{},
+		files:       map[string]string{},
+		in:          in,
+	}
+	var err error
+	if d.trace, err = in.TraceCommands(d.traced); err != nil {
+		return nil, err
+	}
+
+	return d, nil
+}
+
+// Close detaches the debugger from the interpreter. It must be called on the
+// goroutine using the interpreter while the interpreter is not stopped.
+func (d *Debugger) Close() error {
+	return d.trace.Remove()
+}
+
+// SetStopHandler sets the function called when the interpreter stops at a
+// breakpoint, after a step or after Pause. The interpreter does not stop while
+// there is no handler.
+func (d *Debugger) SetStopHandler(h StopHandler) {
+	d.mu.Lock()
+	d.handler = h
+	d.mu.Unlock()
+}
+
+// SetBreakpoint sets a breakpoint at line of file. Relative file names are
+// resolved in the current directory.
+func (d *Debugger) SetBreakpoint(file string, line int) {
+	d.mu.Lock()
+
+	defer d.mu.Unlock()
+
+	file = normalize(file)
+	if d.breakpoints[file] == nil {
+		d.breakpoints[file] = map[int]bool{}
+	}
+	d.breakpoints[file][line] = true
+}
+
+// ClearBreakpoint removes the breakpoint at line of file, if any.
+func (d *Debugger) ClearBreakpoint(file string, line int) {
+	d.mu.Lock()
+
+	defer d.mu.Unlock()
+
+	file = normalize(file)
+	delete(d.breakpoints[file], line)
+	if len(d.breakpoints[file]) == 0 {
+		delete(d.breakpoints, file)
+	}
+}
+
+// SetBreakpoints replaces all breakpoints of file by breakpoints at lines.
+func (d *Debugger) SetBreakpoints(file string, lines []int) {
+	d.mu.Lock()
+
+	defer d.mu.Unlock()
+
+	file = normalize(file)
+	delete(d.breakpoints, file)
+	for _, v := range lines {
+		if d.breakpoints[file] == nil {
+			d.breakpoints[file] = map[int]bool{}
+		}
+		d.breakpoints[file][v] = true
+	}
+}
+
+// ClearBreakpoints removes all breakpoints.
+func (d *Debugger) ClearBreakpoints() {
+	d.mu.Lock()
+	d.breakpoints = map[string]map[int]bool{}
+	d.mu.Unlock()
+}
+
+// Pause makes the interpreter stop before executing the next command.
+func (d *Debugger) Pause() { atomic.StoreInt32(&d.pause, 1) }
+
+func normalize(file string) string {
+	if file == "" {
+		return ""
+	}
+
+	if abs, err := filepath.Abs(file); err == nil {
+		file = abs
+	}
+	return filepath.Clean(file)
+}
+
+// traced is the tcl.CmdTraceProc of the debugger.
+func (d *Debugger) traced(in *tcl.Interp, level int, args []*tcl.Obj) error {
+	d.mu.Lock()
+	handler := d.handler
+	breakpoints := len(d.breakpoints) != 0
+	d.mu.Unlock()
+	if handler == nil || !breakpoints && d.mode == Continue && atomic.LoadInt32(&d.pause) == 0 {
+		return nil
+	}
+
+	frame, err := in.Frame(0)
+	if err != nil {
+		return nil
+	}
+
+	file, line := d.normalizeFile(frame["file"]), atoi(frame["line"])
+	newLine := file != d.lastFile || line != d.lastLine
+	d.lastFile, d.lastLine = file, line
+	var reason string
+	var procLevel int
+	switch {
+	case atomic.CompareAndSwapInt32(&d.pause, 1, 0):
+		reason = "pause"
+		procLevel = d.procLevel()
+	case d.mode != Continue:
+		procLevel = d.procLevel()
+		if d.stepDone(file, line, procLevel) {
+			reason = "step"
+		}
+	}
+	if reason == "" && newLine && d.isBreakpoint(file, line) {
+		reason = "breakpoint"
+		procLevel = d.procLevel()
+	}
+	if reason == "" {
+		return nil
+	}
+
+	s := &Stop{
+		Reason: reason,
+		File:   file,
+		Line:   line,
+		Cmd:    frame["cmd"],
+		Level:  procLevel,
+		d:      d,
+		valid:  true,
+	}
+	d.mode = handler(s)
+	s.valid = false
+	d.stepFile, d.stepLine, d.stepLevel = file, line, procLevel
+	return nil
+}
+
+// normalizeFile returns the normalized form of a file name reported by Tcl.
+func (d *Debugger) normalizeFile(file string) string {
+	r, ok := d.files[file]
+	if !ok {
+		r = normalize(file)
+		d.files[file] = r
+	}
+	return r
+}
+
+func (d *Debugger) isBreakpoint(file string, line int) bool {
+	d.mu.Lock()
+
+	defer d.mu.Unlock()
+
+	return d.breakpoints[file][line]
+}
+
+// stepDone reports whether a step in progress is complete at the command at
+// line of file executed at procedure level procLevel.
+func (d *Debugger) stepDone(file string, line, procLevel int) bool {
+	switch d.mode {
+	case StepIn:
+		return file != d.stepFile || line != d.stepLine || procLevel != d.stepLevel
+	case StepOver:
+		return procLevel < d.stepLevel || procLevel == d.stepLevel && (file != d.stepFile || line != d.stepLine)
+	case StepOut:
+		return procLevel < d.stepLevel
+	default:
+		return false
+	}
+}
+
+// procLevel returns the current procedure level as reported by 'info level'.
+func (d *Debugger) procLevel() int {
+	r, err := d.in.Call("info", "level")
+	if err != nil {
+		return 0
+	}
+
+	n, _ := r.Int()
+	return int(n)
+}
+
+func atoi(s string) int {
+	n, _ := strconv.Atoi(s)
+	return n
+}
+
+// Stop describes the command the interpreter stopped at. Its methods can be
+// used only by the StopHandler it was passed to and only before the handler
+// returns.
+type Stop struct {
+	// Reason is why the interpreter stopped: "breakpoint", "step" or
+	// "pause".
+	Reason string
+	// File is the normalized name of the file containing the command or
+	// the empty string if the command does not come from a file.
+	File string
+	// Line is the line of the command. It is relative to the body of the
+	// procedure or to the evaluated script if File is the empty string.
+	Line int
+	// Cmd is the source text of the command.
+	Cmd string
+	// Level is the procedure level of the command as reported by 'info
+	// level', zero for the global level.
+	Level int
+
+	d     *Debugger
+	valid bool
+}
+
+// Frame describes a procedure activation of a stopped interpreter.
+type Frame struct {
+	// Level is the procedure level of the frame, zero for the global
+	// level.
+	Level int
+	// Proc is the fully qualified name of the procedure or the empty
+	// string if not known, for example at the global level.
+	Proc string
+	// File, Line and Cmd describe the command being executed by the frame
+	// like the fields of Stop do.
+	File string
+	Line int
+	Cmd  string
+}
+
+// Variable is a variable of a stopped interpreter.
+type Variable struct {
+	Name string
+	// Value is the value of a scalar variable or, for arrays, the list of
+	// element names and values returned by 'array get'.
+	Value string
+	Array bool
+}
+
+func (s *Stop) check() error {
+	if !s.valid {
+		return fmt.Errorf("interpreter is no longer stopped")
+	}
+
+	return nil
+}
+
+// Stack returns the active procedure frames, innermost first, or an error, if
+// any.
+func (s *Stop) Stack() ([]Frame, error) {
+	if err := s.check(); err != nil {
+		return nil, err
+	}
+
+	var r []Frame
+	seen := map[int]bool{}
+	level := s.Level
+	for k := 0; ; k-- {
+		f, err := s.d.in.Frame(k)
+		if err != nil {
+			break
+		}
+
+		if rel, ok := f["level"]; ok {
+			level = s.Level - atoi(rel)
+		}
+		if seen[level] {
+			continue
+		}
+
+		seen[level] = true
+		r = append(r, Frame{
+			Level: level,
+			Proc:  f["proc"],
+			File:  s.d.normalizeFile(f["file"]),
+			Line:  atoi(f["line"]),
+			Cmd:   f["cmd"],
+		})
+	}
+	if len(r) == 0 {
+		r = append(r, Frame{Level: s.Level, File: s.File, Line: s.Line, Cmd: s.Cmd})
+	}
+	return r, nil
+}
+
+// stopVariables is a lambda returning the variables visible at a procedure
+// level as a list of name, array flag and value triples.
+const stopVariables = `{level} {
+	set r {}
+	foreach n [uplevel #$level {info vars}] {
+		upvar #$level $n v
+		if {[array exists v]} {
+			lappend r $n 1 [array get v]
+		} elseif {[info exists v]} {
+			lappend r $n 0 $v
+		}
+	}
+	set r
+}`
+
+// Variables returns the variables visible at procedure level, sorted by name,
+// or an error, if any. Level zero is the global level.
+func (s *Stop) Variables(level int) ([]Variable, error) {
+	if err := s.check(); err != nil {
+		return nil, err
+	}
+
+	o, err := s.d.in.Call("apply", stopVariables, level)
+	if err != nil {
+		return nil, err
+	}
+
+	items, err := o.List()
+	if err != nil {
+		return nil, err
+	}
+
+	var r []Variable
+	for i := 0; i+2 < len(items); i += 3 {
+		r = append(r, Variable{
+			Name:  items[i].String(),
+			Array: items[i+1].String() == "1",
+			Value: items[i+2].String(),
+		})
+	}
+	sort.Slice(r, func(i, j int) bool { return r[i].Name < r[j].Name })
+	return r, nil
+}
+
+// Eval evaluates script at procedure level like 'uplevel #level' does and
+// returns the result or an error, if any. Breakpoints are ignored while the
+// script executes.
+func (s *Stop) Eval(level int, script string) (string, error) {
+	if err := s.check(); err != nil {
+		return "", err
+	}
+
+	r, err := s.d.in.Call("uplevel", fmt.Sprintf("#%d", level), script)
+	if err != nil {
+		return "", err
+	}
+
+	return r.String(), nil
+}
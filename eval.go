@@ -0,0 +1,183 @@
+// Copyright 2026 The Tcl Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tcl // import "modernc.org/tcl"
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"reflect"
+	"sync"
+	"unsafe"
+
+	"modernc.org/libc"
+	"modernc.org/tcl/lib"
+)
+
+// EvalContext is like Eval but cancels the evaluation when ctx is done. The
+// error returned for a canceled evaluation wraps ctx.Err(). The interpreter
+// remains usable after a cancellation.
+func (in *Interp) EvalContext(ctx context.Context, script string) (r string, err error) {
+	err = in.do(func() error { r, err = in.evalContext(ctx, script); return err })
+	return r, err
+}
+
+func (in *Interp) evalContext(ctx context.Context, script string) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+
+	var (
+		canceled bool
+		finished bool
+		mu       sync.Mutex
+		stop     = make(chan struct{})
+	)
+	go func() {
+		select {
+		case <-ctx.Done():
+			mu.Lock()
+			if !finished {
+				tls := libc.NewTLS()
+				tcl.XTcl_CancelEval(tls, in.interp, 0, 0, tcl.TCL_CANCEL_UNWIND)
+				tls.Close()
+				canceled = true
+			}
+			mu.Unlock()
+		case <-stop:
+		}
+	}()
+	s, err := in.evalScript(script, func(s uintptr, n int32) int32 { return tcl.XTcl_Eval(in.tls, in.interp, s) })
+	mu.Lock()
+	finished = true
+	mu.Unlock()
+	close(stop)
+	if !canceled {
+		return s, err
+	}
+
+	in.resetCancellation()
+	if err != nil {
+		return s, fmt.Errorf("%s: %w", err, ctx.Err())
+	}
+
+	return s, nil
+}
+
+// resetCancellation discards any pending Tcl_CancelEval request so the next
+// evaluation is not affected by it.
+func (in *Interp) resetCancellation() {
+	if tcl.XTcl_AsyncReady(in.tls) != 0 {
+		tcl.XTcl_AsyncInvoke(in.tls, 0, tcl.TCL_OK)
+	}
+	tcl.XTclResetCancellation(in.tls, in.interp, 1)
+}
+
+// EvalGlobal is like Eval but evaluates script at the global level, ie. in
+// the global namespace and outside of any procedure call frame.
+func (in *Interp) EvalGlobal(script string) (string, error) {
+	return in.evalString(script, func(s uintptr, n int32) int32 {
+		return tcl.XTcl_EvalEx(in.tls, in.interp, s, n, tcl.TCL_EVAL_GLOBAL)
+	})
+}
+
+// EvalDirect is like Eval but evaluates script directly, without compiling
+// it to bytecode first. It can be faster for scripts evaluated only once.
+func (in *Interp) EvalDirect(script string) (string, error) {
+	return in.evalString(script, func(s uintptr, n int32) int32 {
+		return tcl.XTcl_EvalEx(in.tls, in.interp, s, n, tcl.TCL_EVAL_DIRECT)
+	})
+}
+
+// Call invokes the command cmd with args and returns the result of the command
+// or an error, if any. Every argument becomes exactly one word of the command,
+// no substitutions are performed on it, so arguments containing characters
+// special to Tcl, like braces, brackets or dollar signs, need no quoting. The
+// arguments are converted to Tcl values as described in RegisterFunc.
+func (in *Interp) Call(cmd string, args ...interface{}) (*Obj, error) {
+	objv := make([]*Obj, 1+len(args))
+	objv[0] = NewStringObj(cmd)
+	for i, v := range args {
+		objv[i+1] = toObj(reflect.ValueOf(v))
+	}
+	var r *Obj
+	if err := in.do(func() error {
+		_, err := in.evalFunc(func() int32 {
+			rc := in.evalObjv(objv, 0)
+			if rc == tcl.TCL_OK {
+				r = in.newObj(tcl.XTcl_GetObjResult(in.tls, in.interp))
+			}
+			return rc
+		})
+		return err
+	}); err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+// MustCall is like Call but panics on error.
+func (in *Interp) MustCall(cmd string, args ...interface{}) *Obj {
+	r, err := in.Call(cmd, args...)
+	if err != nil {
+		panic(err)
+	}
+
+	return r
+}
+
+// EvalFile evaluates the file at path like the source command does. 'info
+// script', 'info frame' and the -errorinfo of an error report path and the
+// line numbers within the file.
+func (in *Interp) EvalFile(path string) (string, error) {
+	return in.evalString(path, func(s uintptr, n int32) int32 { return tcl.XTcl_EvalFile(in.tls, in.interp, s) })
+}
+
+// EvalFS is like EvalFile but reads the UTF-8 encoded file name from fsys.
+func (in *Interp) EvalFS(fsys fs.FS, name string) (string, error) {
+	b, err := fs.ReadFile(fsys, name)
+	if err != nil {
+		return "", err
+	}
+
+	return in.EvalNamed(string(b), name)
+}
+
+// EvalNamed is like Eval but evaluates script as if it was read from the file
+// filename by EvalFile.
+func (in *Interp) EvalNamed(script, filename string) (string, error) {
+	return in.evalString(script, func(s uintptr, n int32) int32 { return in.evalNamed(s, n, filename) })
+}
+
+// evalNamed mirrors what Tcl_FSEvalFileEx does after reading the file.
+func (in *Interp) evalNamed(script uintptr, n int32, filename string) int32 {
+	iPtr := (*tcl.Interp)(unsafe.Pointer(in.interp))
+	path := newStringObj(in.tls, filename)
+	incrRefCount(path)
+	oldScriptFile := iPtr.FscriptFile
+	iPtr.FscriptFile = path
+	// Force the evaluator to open a frame for a sourced file.
+	iPtr.FevalFlags |= tcl.TCL_EVAL_FILE
+	rc := tcl.XTclEvalEx(in.tls, in.interp, script, n, 0, 1, 0, script)
+	if iPtr.FscriptFile != 0 {
+		decrRefCount(in.tls, iPtr.FscriptFile)
+	}
+	iPtr.FscriptFile = oldScriptFile
+	switch rc {
+	case tcl.TCL_RETURN:
+		rc = tcl.XTclUpdateReturnInfo(in.tls, in.interp)
+	case tcl.TCL_ERROR:
+		const limit = 150
+		var overflow string
+		if len(filename) > limit {
+			filename = filename[:limit]
+			overflow = "..."
+		}
+		msg := fmt.Sprintf("\n    (file \"%s%s\" line %d)", filename, overflow, tcl.XTcl_GetErrorLine(in.tls, in.interp))
+		tcl.XTcl_AppendObjToErrorInfo(in.tls, in.interp, newStringObj(in.tls, msg))
+	}
+	return rc
+}
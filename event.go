@@ -0,0 +1,312 @@
+// Copyright 2026 The Tcl Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tcl // import "modernc.org/tcl"
+
+import (
+	"context"
+	"math"
+	"time"
+	"unsafe"
+
+	"modernc.org/libc"
+	"modernc.org/tcl/lib"
+)
+
+// Flags of DoOneEvent.
+const (
+	WindowEvents = tcl.TCL_WINDOW_EVENTS // Process window system events.
+	FileEvents   = tcl.TCL_FILE_EVENTS   // Process file events and functions queued by Post.
+	TimerEvents  = tcl.TCL_TIMER_EVENTS  // Process timer events.
+	IdleEvents   = tcl.TCL_IDLE_EVENTS   // Process idle callbacks.
+	AllEvents    = tcl.TCL_ALL_EVENTS    // Process all kinds of events.
+	DontWait     = tcl.TCL_DONT_WAIT     // Do not block if there is no event ready.
+)
+
+// postedFunc is a function queued by Post.
+type postedFunc struct {
+	in *Interp
+	f  func(*Interp)
+}
+
+var (
+	postSetupP = *(*uintptr)(unsafe.Pointer(&struct {
+		f func(tls *libc.TLS, clientData uintptr, flags int32)
+	}{postSetup}))
+	postCheckP = *(*uintptr)(unsafe.Pointer(&struct {
+		f func(tls *libc.TLS, clientData uintptr, flags int32)
+	}{postCheck}))
+	postEventP = *(*uintptr)(unsafe.Pointer(&struct {
+		f func(tls *libc.TLS, evPtr uintptr, flags int32) int32
+	}{postEvent}))
+	postDeleteP = *(*uintptr)(unsafe.Pointer(&struct {
+		f func(tls *libc.TLS, evPtr, clientData uintptr) int32
+	}{postDelete}))
+	timerP = *(*uintptr)(unsafe.Pointer(&struct {
+		f func(tls *libc.TLS, clientData uintptr)
+	}{timerFired}))
+)
+
+// root returns the interpreter owning the TLS of in.
+func (in *Interp) root() *Interp {
+	for in.parent != nil { // Children share the TLS of their parent.
+		in = in.parent
+	}
+	return in
+}
+
+// createEventSource installs the event source servicing the functions queued
+// by Post.
+func (in *Interp) createEventSource() {
+	in.source = addObject(in)
+	tcl.XTcl_CreateEventSource(in.tls, postSetupP, postCheckP, in.source)
+}
+
+// deleteEventSource removes the event source installed by createEventSource
+// and discards the queued functions.
+func (in *Interp) deleteEventSource() {
+	tcl.XTcl_DeleteEventSource(in.tls, postSetupP, postCheckP, in.source)
+	tcl.XTcl_DeleteEvents(in.tls, postDeleteP, in.source)
+	removeObject(in.source)
+	in.source = 0
+	in.postMu.Lock()
+	in.posted = nil
+	in.postMu.Unlock()
+}
+
+// Post queues f to be called with the interpreter by the event loop, for
+// example while the interpreter executes DoOneEvent, RunEventLoop, 'vwait' or
+// 'update', and wakes up the event loop if it is waiting for events.
+// Functions are called in the order they were queued. Post may be called from
+// any goroutine.
+func (in *Interp) Post(f func(*Interp)) {
+	root := in.root()
+	root.postMu.Lock()
+	root.posted = append(root.posted, postedFunc{in, f})
+	root.postMu.Unlock()
+	withObjTLS(func(tls *libc.TLS) { tcl.XTcl_ThreadAlert(tls, root.thread) })
+}
+
+// postSetup is the setupProc of the event source of Post.
+func postSetup(tls *libc.TLS, clientData uintptr, flags int32) {
+	if flags&FileEvents == 0 {
+		return
+	}
+
+	in := getObject(clientData).(*Interp)
+	in.postMu.Lock()
+	pending := len(in.posted) != 0
+	in.postMu.Unlock()
+	if !pending {
+		return
+	}
+
+	sz := int(unsafe.Sizeof(tcl.Tcl_Time{}))
+	p := tls.Alloc(sz)
+
+	defer tls.Free(sz)
+
+	setTime(p, sz, 0, 0)
+	tcl.XTcl_SetMaxBlockTime(tls, p)
+}
+
+// postCheck is the checkProc of the event source of Post. It moves the
+// functions queued by Post to the Tcl event queue.
+func postCheck(tls *libc.TLS, clientData uintptr, flags int32) {
+	if flags&FileEvents == 0 {
+		return
+	}
+
+	in := getObject(clientData).(*Interp)
+	in.postMu.Lock()
+	posted := in.posted
+	in.posted = nil
+	in.postMu.Unlock()
+	for _, v := range posted {
+		queueEvent(tls, postEventP, addObject(v))
+	}
+}
+
+// queueEvent appends to the Tcl event queue an event handled by proc and
+// carrying data.
+func queueEvent(tls *libc.TLS, proc, data uintptr) {
+	// The data follows the Tcl_Event.
+	sz := unsafe.Sizeof(tcl.Tcl_Event{})
+	p := tcl.XTcl_Alloc(tls, uint32(sz+unsafe.Sizeof(uintptr(0))))
+	*(*tcl.Tcl_Event)(unsafe.Pointer(p)) = tcl.Tcl_Event{Fproc: proc}
+	*(*uintptr)(unsafe.Pointer(p + sz)) = data
+	tcl.XTcl_QueueEvent(tls, p, tcl.TCL_QUEUE_TAIL)
+}
+
+// eventData returns the data of an event queued by queueEvent.
+func eventData(evPtr uintptr) uintptr {
+	return *(*uintptr)(unsafe.Pointer(evPtr + unsafe.Sizeof(tcl.Tcl_Event{})))
+}
+
+// postEvent calls a function queued by Post.
+func postEvent(tls *libc.TLS, evPtr uintptr, flags int32) int32 {
+	if flags&FileEvents == 0 {
+		return 0
+	}
+
+	h := eventData(evPtr)
+	v := getObject(h).(postedFunc)
+	removeObject(h)
+	v.in.callback(func() { v.f(v.in) })
+	return 1
+}
+
+// postDelete selects the events created by postCheck for deletion.
+func postDelete(tls *libc.TLS, evPtr, clientData uintptr) int32 {
+	if (*tcl.Tcl_Event)(unsafe.Pointer(evPtr)).Fproc != postEventP {
+		return 0
+	}
+
+	removeObject(eventData(evPtr))
+	return 1
+}
+
+// DoOneEvent processes a single event of the kinds selected by flags, a
+// combination of WindowEvents, FileEvents, TimerEvents, IdleEvents, AllEvents
+// and DontWait, like Tcl_DoOneEvent does. It blocks until an event is ready
+// unless flags include DontWait. DoOneEvent reports whether an event was
+// processed. Errors in event handlers are reported by 'bgerror'.
+func (in *Interp) DoOneEvent(flags int) (r bool, err error) {
+	err = in.do(func() error { r = in.doOneEvent(flags); return nil })
+	return r, err
+}
+
+// MustDoOneEvent is like DoOneEvent but panics on error.
+func (in *Interp) MustDoOneEvent(flags int) bool {
+	r, err := in.DoOneEvent(flags)
+	if err != nil {
+		panic(err)
+	}
+
+	return r
+}
+
+func (in *Interp) doOneEvent(flags int) bool {
+	tcl.XTcl_Preserve(in.tls, in.interp)
+
+	defer tcl.XTcl_Release(in.tls, in.interp)
+
+	in.releaseObjects()
+	return tcl.XTcl_DoOneEvent(in.tls, int32(flags)) != 0
+}
+
+// RunEventLoop processes events until ctx is done and returns ctx.Err(), or
+// an error, if any, preventing the use of the interpreter.
+func (in *Interp) RunEventLoop(ctx context.Context) error {
+	return in.do(func() error { return in.runEventLoop(ctx) })
+}
+
+func (in *Interp) runEventLoop(ctx context.Context) error {
+	done := make(chan struct{})
+
+	defer close(done)
+
+	go func() {
+		select {
+		case <-ctx.Done():
+			// Only servicing an event makes Tcl_DoOneEvent return, an
+			// alert alone does not.
+			in.Post(func(*Interp) {})
+		case <-done:
+		}
+	}()
+	for ctx.Err() == nil {
+		in.doOneEvent(AllEvents)
+	}
+	return ctx.Err()
+}
+
+// Timer is a function scheduled by After.
+type Timer struct {
+	f     func()
+	h     uintptr
+	in    *Interp
+	token uintptr
+}
+
+// After arranges for the event loop to call f once at least d elapsed, like
+// 'after' does for scripts. The returned Timer can be used to cancel the
+// call. After and Timer.Stop must be called by the goroutine using the
+// interpreter, use Post to schedule calls from other goroutines. They return
+// ErrConcurrentUse otherwise, unless the interpreter is a bound one. Closing
+// the interpreter stops its pending timers.
+func (in *Interp) After(d time.Duration, f func()) (t *Timer, err error) {
+	err = in.do(func() error { t = in.after(d, f); return nil })
+	return t, err
+}
+
+// MustAfter is like After but panics on error.
+func (in *Interp) MustAfter(d time.Duration, f func()) *Timer {
+	t, err := in.After(d, f)
+	if err != nil {
+		panic(err)
+	}
+
+	return t
+}
+
+func (in *Interp) after(d time.Duration, f func()) (t *Timer) {
+	ms := (d + time.Millisecond - 1) / time.Millisecond
+	switch {
+	case ms < 0:
+		ms = 0
+	case ms > math.MaxInt32:
+		ms = math.MaxInt32
+	}
+	t = &Timer{f: f, in: in}
+	t.h = addObject(t)
+	t.token = tcl.XTcl_CreateTimerHandler(in.tls, int32(ms), timerP, t.h)
+	root := in.root()
+	if root.timers == nil {
+		root.timers = map[*Timer]struct{}{}
+	}
+	root.timers[t] = struct{}{}
+	return t
+}
+
+func timerFired(tls *libc.TLS, clientData uintptr) {
+	t := getObject(clientData).(*Timer)
+	removeObject(clientData)
+	delete(t.in.root().timers, t)
+	t.token = 0
+	t.in.callback(t.f)
+}
+
+// stopTimers stops the pending timers of in or, for a root interpreter, of
+// in and its children. Timer handlers belong to the Tcl thread, not to the
+// interpreter, so they would otherwise fire after in is closed.
+func (in *Interp) stopTimers() {
+	for t := range in.root().timers {
+		if in.parent == nil || t.in == in {
+			t.stop()
+		}
+	}
+}
+
+// Stop prevents the call of the function scheduled by After. It reports
+// whether the call was prevented, ie. false if the function was already
+// called, the timer was already stopped or the interpreter was closed.
+func (t *Timer) Stop() (r bool, err error) {
+	if err = t.in.do(func() error { r = t.stop(); return nil }); err == ErrClosed {
+		err = nil
+	}
+	return r, err
+}
+
+func (t *Timer) stop() bool {
+	if t.token == 0 {
+		return false
+	}
+
+	tcl.XTcl_DeleteTimerHandler(t.in.tls, t.token)
+	removeObject(t.h)
+	delete(t.in.root().timers, t)
+	t.token = 0
+	return true
+}
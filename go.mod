@@ -3,6 +3,7 @@ module modernc.org/tcl
 go 1.18
 
 require (
+	golang.org/x/sys v0.0.0-20220811171246-fbc7d0a398ab
 	modernc.org/ccgo/v3 v3.16.13
 	modernc.org/httpfs v1.0.6
 	modernc.org/libc v1.22.6
@@ -17,7 +18,6 @@ require (
 	github.com/mattn/go-isatty v0.0.16 // indirect
 	github.com/remyoudompheng/bigfft v0.0.0-20230129092748-24d4a6f8daec // indirect
 	golang.org/x/mod v0.3.0 // indirect
-	golang.org/x/sys v0.0.0-20220811171246-fbc7d0a398ab // indirect
 	golang.org/x/tools v0.0.0-20201124115921-2c860bdd6e78 // indirect
 	golang.org/x/xerrors v0.0.0-20200804184101-5ec99f83aff1 // indirect
 	lukechampine.com/uint128 v1.2.0 // indirect
@@ -0,0 +1,123 @@
+// Copyright 2026 The Tcl Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tcl // import "modernc.org/tcl"
+
+import (
+	"fmt"
+	"runtime"
+	"strconv"
+	"sync/atomic"
+	"unsafe"
+
+	"modernc.org/libc"
+	"modernc.org/tcl/lib"
+)
+
+const (
+	goObjName         = "goobj"
+	internalRepOffset = unsafe.Offsetof(tcl.Tcl_Obj{}.FinternalRep)
+)
+
+var (
+	_                   = copy(cGoObjName[:], goObjName)
+	cGoObjName          [len(goObjName) + 1]byte
+	goObjTypeRegistered bool // Guarded by objTLSMu.
+)
+
+// goObjType is the Tcl_ObjType of values created by NewGoObj. The internal
+// representation is the handle of a goValue, the string representation is
+// goobj followed by the handle.
+var goObjType = tcl.Tcl_ObjType{
+	Fname: uintptr(unsafe.Pointer(&cGoObjName[0])),
+	FfreeIntRepProc: *(*uintptr)(unsafe.Pointer(&struct {
+		f func(tls *libc.TLS, objPtr uintptr)
+	}{goObjFree})),
+	FdupIntRepProc: *(*uintptr)(unsafe.Pointer(&struct {
+		f func(tls *libc.TLS, srcPtr, dupPtr uintptr)
+	}{goObjDup})),
+	FupdateStringProc: *(*uintptr)(unsafe.Pointer(&struct {
+		f func(tls *libc.TLS, objPtr uintptr)
+	}{goObjUpdateString})),
+}
+
+// goValue is a Go value referenced by refs Tcl_Objs.
+type goValue struct {
+	refs int32
+	v    interface{}
+}
+
+// goObjHandle returns a pointer to the handle stored in the internal
+// representation of the Tcl_Obj at objPtr.
+func goObjHandle(objPtr uintptr) *uintptr {
+	return (*uintptr)(unsafe.Pointer(objPtr + internalRepOffset))
+}
+
+// NewGoObj returns a newly created Obj wrapping the Go value v. The Tcl
+// value is opaque, its string representation is a unique name assigned to v,
+// and it can be stored in lists, dictionaries and variables. Use
+// Obj.GoValue to retrieve v. The reference to v is released when the last
+// Tcl value holding it is freed or converted to another type, for example by
+// using it as a list. The name is not a reference to v, a string equal to it
+// does not resolve to v, so scripts cannot forge references to Go values.
+func NewGoObj(v interface{}) (r *Obj) {
+	withObjTLS(func(tls *libc.TLS) {
+		if !goObjTypeRegistered {
+			tcl.XTcl_RegisterObjType(tls, uintptr(unsafe.Pointer(&goObjType)))
+			goObjTypeRegistered = true
+		}
+		p := tcl.XTcl_NewObj(tls)
+		tcl.XTcl_InvalidateStringRep(tls, p)
+		*goObjHandle(p) = addObject(&goValue{refs: 1, v: v})
+		(*tcl.Tcl_Obj)(unsafe.Pointer(p)).FtypePtr = uintptr(unsafe.Pointer(&goObjType))
+		r = newObj(p)
+	})
+	return r
+}
+
+// GoValue returns the Go value wrapped by o or an error, if any. O must be a
+// value created by NewGoObj or a copy of it.
+func (o *Obj) GoValue() (r interface{}, err error) {
+	r, ok := goObjValue(o.p)
+	runtime.KeepAlive(o)
+	if !ok {
+		return nil, fmt.Errorf("not a Go value: %q", o)
+	}
+
+	return r, nil
+}
+
+// goObjValue returns the Go value wrapped by the Tcl_Obj at objPtr, if any.
+// It does not touch the string representation of objPtr.
+func goObjValue(objPtr uintptr) (interface{}, bool) {
+	if (*tcl.Tcl_Obj)(unsafe.Pointer(objPtr)).FtypePtr != uintptr(unsafe.Pointer(&goObjType)) {
+		return nil, false
+	}
+
+	return getObject(*goObjHandle(objPtr)).(*goValue).v, true
+}
+
+func goObjFree(tls *libc.TLS, objPtr uintptr) {
+	h := *goObjHandle(objPtr)
+	if atomic.AddInt32(&getObject(h).(*goValue).refs, -1) == 0 {
+		removeObject(h)
+	}
+}
+
+func goObjDup(tls *libc.TLS, srcPtr, dupPtr uintptr) {
+	h := *goObjHandle(srcPtr)
+	atomic.AddInt32(&getObject(h).(*goValue).refs, 1)
+	*goObjHandle(dupPtr) = h
+	(*tcl.Tcl_Obj)(unsafe.Pointer(dupPtr)).FtypePtr = (*tcl.Tcl_Obj)(unsafe.Pointer(srcPtr)).FtypePtr
+}
+
+func goObjUpdateString(tls *libc.TLS, objPtr uintptr) {
+	s := goObjName + strconv.FormatUint(uint64(*goObjHandle(objPtr)), 10)
+	p := tcl.XTcl_Alloc(tls, uint32(len(s)+1))
+	copy((*libc.RawMem)(unsafe.Pointer(p))[:len(s):len(s)], s)
+	*(*byte)(unsafe.Pointer(p + uintptr(len(s)))) = 0
+	o := (*tcl.Tcl_Obj)(unsafe.Pointer(objPtr))
+	o.Fbytes = p
+	o.Flength = int32(len(s))
+}
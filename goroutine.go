@@ -0,0 +1,224 @@
+// Copyright 2026 The Tcl Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tcl // import "modernc.org/tcl"
+
+import (
+	"errors"
+	"runtime"
+	"sync"
+)
+
+// ErrConcurrentUse is returned when an interpreter not created by
+// NewBoundInterp is used by a goroutine while another goroutine executes Tcl
+// code of the interpreter. The detection is best effort, it is not a
+// substitute for proper synchronization. In particular, while the interpreter
+// executes Go code, like a command implemented in Go, a use by another
+// goroutine cannot be told from a use by that code and is executed as a
+// nested use.
+var ErrConcurrentUse = errors.New("tcl: concurrent use of an interpreter by multiple goroutines")
+
+// ErrClosed is returned when a closed interpreter is used.
+var ErrClosed = errors.New("tcl: use of a closed interpreter")
+
+// executor is the goroutine owning an interpreter created by NewBoundInterp.
+type executor struct {
+	mu   sync.Mutex
+	quit chan struct{}
+	work chan func()
+}
+
+// NewBoundInterp is like NewInterp but the returned interpreter owns a
+// dedicated goroutine locked to an OS thread. Methods called from any
+// goroutine execute on that goroutine, one at a time, and block until the
+// goroutine is available. The exception are uses while the interpreter
+// executes Go code, like a command implemented in Go or a function passed to
+// Do, which cannot be told from uses by that code. They are executed directly,
+// as nested uses, by the calling goroutine. It is safe to use the interpreter
+// from multiple goroutines concurrently.
+func NewBoundInterp() (*Interp, error) {
+	x := &executor{
+		quit: make(chan struct{}),
+		work: make(chan func()),
+	}
+	var in *Interp
+	ch := make(chan error, 1)
+	go func() {
+		runtime.LockOSThread()
+
+		defer runtime.UnlockOSThread()
+
+		var err error
+		if in, err = NewInterp(); err != nil {
+			ch <- err
+			return
+		}
+
+		in.exec = x
+		ch <- nil
+		for {
+			select {
+			case f := <-x.work:
+				f()
+			case <-x.quit:
+				return
+			}
+		}
+	}()
+	if err := <-ch; err != nil {
+		return nil, err
+	}
+
+	return in, nil
+}
+
+// MustNewBoundInterp is like NewBoundInterp but panics on error.
+func MustNewBoundInterp() *Interp {
+	in, err := NewBoundInterp()
+	if err != nil {
+		panic(err)
+	}
+
+	return in
+}
+
+// Do calls f with the interpreter. For an interpreter created by
+// NewBoundInterp, f is executed on the goroutine owning the interpreter,
+// unless Do is called while the interpreter executes Go code, see
+// NewBoundInterp. Do returns after f returns. A panic in f is propagated to
+// the caller of Do. Do returns ErrClosed, without calling f, if the
+// interpreter was closed, or ErrConcurrentUse, see enter.
+func (in *Interp) Do(f func(*Interp)) error {
+	return in.do(func() error {
+		in.callback(func() { f(in) })
+		return nil
+	})
+}
+
+// do calls f with the interpreter marked as being used, on the goroutine
+// owning a bound interpreter if necessary, and returns the error of f or the
+// error preventing the use of the interpreter, see enter. All methods using
+// the interpreter go through do.
+func (in *Interp) do(f func() error) error {
+	return in.use(false, f)
+}
+
+// use implements do. Owner reports whether the caller is the goroutine owning
+// a bound interpreter.
+func (in *Interp) use(owner bool, f func() error) error {
+	root, marshal, err := in.enter(owner)
+	if err != nil {
+		return err
+	}
+
+	if marshal {
+		return in.exec.run(func() error { return in.use(true, f) })
+	}
+
+	defer root.exit()
+
+	return f()
+}
+
+// enter marks the start of a use of the interpreter and returns its root
+// interpreter, the end of the use must be marked by calling its exit method. A use
+// can start only when no goroutine executes Tcl code of the interpreter:
+// either it is not used or all its uses are executing Go code called by Tcl,
+// see callback. Otherwise enter returns ErrConcurrentUse or, for a bound
+// interpreter, waits. Enter reports whether the use must be marshalled to the
+// goroutine owning a bound interpreter, which is the case when the
+// interpreter is not used. It returns ErrClosed if the interpreter was
+// closed.
+func (in *Interp) enter(owner bool) (root *Interp, marshal bool, err error) {
+	root = in.root()
+	root.guard.Lock()
+
+	defer root.guard.Unlock()
+
+	for {
+		switch {
+		case in.interp == 0:
+			return nil, false, ErrClosed
+		case in.exec != nil && !owner && root.active == 0:
+			return nil, true, nil
+		case root.callbacks == root.active:
+			root.active++
+			return root, false, nil
+		case in.exec == nil || owner:
+			return nil, false, ErrConcurrentUse
+		}
+		root.cond.Wait()
+	}
+}
+
+// exit marks the end of a use of the root interpreter in, see enter.
+func (in *Interp) exit() {
+	in.guard.Lock()
+	in.active--
+	in.cond.Broadcast()
+	in.guard.Unlock()
+}
+
+// callback calls f, Go code called by Tcl on behalf of the interpreter, like
+// a command. While f executes, the interpreter can be used by f and, see
+// enter, by other goroutines. Callback returns after f returns and the uses
+// started while f executed ended, so that at most one goroutine executes Tcl
+// code of the interpreter.
+func (in *Interp) callback(f func()) {
+	root := in.root()
+	root.guard.Lock()
+	root.callbacks++
+	n := root.active
+	root.cond.Broadcast()
+	root.guard.Unlock()
+
+	defer func() {
+		root.guard.Lock()
+		for root.active > n {
+			root.cond.Wait()
+		}
+		root.callbacks--
+		root.guard.Unlock()
+	}()
+
+	f()
+}
+
+// run calls f on the goroutine x and returns its error. A panic in f is
+// propagated to the caller of run. Run returns ErrClosed, without calling f,
+// if x was stopped.
+func (x *executor) run(f func() error) (err error) {
+	var p interface{}
+	done := make(chan struct{})
+	g := func() {
+		defer func() {
+			p = recover()
+			close(done)
+		}()
+
+		err = f()
+	}
+	select {
+	case x.work <- g:
+	case <-x.quit:
+		return ErrClosed
+	}
+	<-done
+	if p != nil {
+		panic(p)
+	}
+	return err
+}
+
+// stop terminates the goroutine owning a bound interpreter.
+func (x *executor) stop() {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+
+	select {
+	case <-x.quit:
+	default:
+		close(x.quit)
+	}
+}
@@ -5,13 +5,20 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"io/ioutil"
+	"net"
 	"os"
+	"strings"
+	"time"
+	"unsafe"
 
 	"modernc.org/libc"
 	"modernc.org/tcl"
+	"modernc.org/tcl/debug"
 	"modernc.org/tcl/internal/tclsh"
+	libtcl "modernc.org/tcl/lib"
 )
 
 const tclLibrary = "TCL_LIBRARY"
@@ -32,5 +39,197 @@ func main() {
 		libc.AtExit(func() { os.RemoveAll(dir) })
 		os.Setenv(tclLibrary, dir)
 	}
+	// Xexit, unlike os.Exit, runs the AtExit functions.
+	if len(os.Args) > 1 && (os.Args[1] == "-cpuprofile" || strings.HasPrefix(os.Args[1], "-cpuprofile=")) {
+		libc.Xexit(libc.NewTLS(), int32(profile()))
+	}
+
+	if len(os.Args) > 1 && (os.Args[1] == "-dap" || strings.HasPrefix(os.Args[1], "-dap=")) {
+		libc.Xexit(libc.NewTLS(), int32(dap()))
+	}
+
 	libc.Start(tclsh.Main)
 }
+
+// profile handles
+//
+//	gotclsh -cpuprofile file script ?arg ...?
+//
+// by evaluating script and writing a pprof profile of the executed Tcl
+// commands to file.
+func profile() int {
+	args := os.Args[2:]
+	out := strings.TrimPrefix(os.Args[1], "-cpuprofile=")
+	if out == os.Args[1] {
+		if len(args) == 0 {
+			return usage()
+		}
+
+		out, args = args[0], args[1:]
+	}
+	if out == "" || len(args) == 0 {
+		return usage()
+	}
+
+	in, script, err := newInterp(args)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	f, err := os.Create(out)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	if err := in.StartProfile(f); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	stop := func() (rc int) {
+		if err := in.StopProfile(); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			rc = 1
+		}
+		if err := f.Close(); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			rc = 1
+		}
+		return rc
+	}
+	// A script calling exit finalizes Tcl, the exit handler stops profiling
+	// before that.
+	exitHandler := *(*uintptr)(unsafe.Pointer(&struct {
+		f func(tls *libc.TLS, clientData uintptr)
+	}{func(tls *libc.TLS, clientData uintptr) { stop() }}))
+	libtcl.XTcl_CreateExitHandler(in.TLS(), exitHandler, 0)
+	rc := evalFile(in, script)
+	libtcl.XTcl_DeleteExitHandler(in.TLS(), exitHandler, 0)
+	if stop() != 0 {
+		rc = 1
+	}
+	return rc
+}
+
+// dap handles
+//
+//	gotclsh -dap addr script ?arg ...?
+//
+// by waiting for a Debug Adapter Protocol client to connect to the TCP
+// address addr and configure the session, then evaluating script under the
+// control of the client.
+func dap() int {
+	args := os.Args[2:]
+	addr := strings.TrimPrefix(os.Args[1], "-dap=")
+	if addr == os.Args[1] {
+		if len(args) == 0 {
+			return usage()
+		}
+
+		addr, args = args[0], args[1:]
+	}
+	if addr == "" || len(args) == 0 {
+		return usage()
+	}
+
+	in, script, err := newInterp(args)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	fmt.Fprintf(os.Stderr, "DAP server listening at %s\n", l.Addr())
+	conn, err := l.Accept()
+	l.Close()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	defer conn.Close()
+
+	d, err := debug.New(in)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	ready := make(chan struct{})
+	served := make(chan error, 1)
+	go func() { served <- d.ServeDAP(conn, ready) }()
+	select {
+	case <-ready:
+	case err := <-served:
+		if err == nil {
+			err = fmt.Errorf("DAP client disconnected")
+		}
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	rc := evalFile(in, script)
+	d.Terminate(rc)
+	// Give the client a chance to disconnect.
+	select {
+	case err := <-served:
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+		}
+	case <-time.After(5 * time.Second):
+	}
+	return rc
+}
+
+// newInterp returns a new interpreter set up for evaluating the script
+// args[0] with arguments args[1:] like tclsh does.
+func newInterp(args []string) (in *tcl.Interp, script string, err error) {
+	if in, err = tcl.NewInterp(); err != nil {
+		return nil, "", err
+	}
+
+	script = args[0]
+	for _, v := range []struct {
+		name  string
+		value interface{}
+	}{
+		{"argv0", script},
+		{"argv", args[1:]},
+		{"argc", len(args) - 1},
+		{"tcl_interactive", 0},
+	} {
+		if err := in.SetVar(v.name, v.value, tcl.GlobalOnly); err != nil {
+			return nil, "", err
+		}
+	}
+	return in, script, nil
+}
+
+// evalFile evaluates script and returns the exit status.
+func evalFile(in *tcl.Interp, script string) int {
+	if _, err := in.EvalFile(script); err != nil {
+		var e *tcl.Error
+		switch {
+		case errors.As(err, &e):
+			fmt.Fprintln(os.Stderr, e.ErrorInfo)
+		default:
+			fmt.Fprintln(os.Stderr, err)
+		}
+		return 1
+	}
+
+	return 0
+}
+
+func usage() int {
+	fmt.Fprintln(os.Stderr, "usage: gotclsh -cpuprofile file script ?arg ...?")
+	fmt.Fprintln(os.Stderr, "       gotclsh -dap addr script ?arg ...?")
+	return 2
+}
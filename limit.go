@@ -0,0 +1,207 @@
+// Copyright 2026 The Tcl Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tcl // import "modernc.org/tcl"
+
+import (
+	"fmt"
+	"time"
+	"unsafe"
+
+	"modernc.org/libc"
+	"modernc.org/tcl/lib"
+)
+
+// LimitType is the kind of a resource limit.
+type LimitType int
+
+// Resource limit types.
+const (
+	LimitCommands LimitType = tcl.TCL_LIMIT_COMMANDS // Limits the number of executed commands.
+	LimitTime     LimitType = tcl.TCL_LIMIT_TIME     // Limits the wall clock time.
+)
+
+// String implements fmt.Stringer.
+func (t LimitType) String() string {
+	switch t {
+	case LimitCommands:
+		return "commands"
+	case LimitTime:
+		return "time"
+	default:
+		return fmt.Sprintf("LimitType(%d)", int(t))
+	}
+}
+
+// LimitError is returned when an evaluation is aborted because a resource
+// limit was exceeded. The interpreter refuses to evaluate further scripts
+// until the limit is raised or removed.
+type LimitError struct {
+	Type LimitType
+	Err  *Error
+}
+
+// Error implements error.
+func (e *LimitError) Error() string { return e.Err.Error() }
+
+// Unwrap returns e.Err.
+func (e *LimitError) Unwrap() error { return e.Err }
+
+// SetCommandLimit limits the interpreter to execute at most n more commands.
+// Resetting the limit clears its exceeded state.
+func (in *Interp) SetCommandLimit(n int) error {
+	return in.do(func() error {
+		count := int((*tcl.Interp)(unsafe.Pointer(in.interp)).FcmdCount)
+		tcl.XTcl_LimitSetCommands(in.tls, in.interp, int32(count+n))
+		tcl.XTcl_LimitTypeSet(in.tls, in.interp, tcl.TCL_LIMIT_COMMANDS)
+		return nil
+	})
+}
+
+// SetTimeLimit limits the evaluation in the interpreter to end before
+// deadline. Resetting the limit clears its exceeded state.
+func (in *Interp) SetTimeLimit(deadline time.Time) error {
+	return in.do(func() error {
+		sz := int(unsafe.Sizeof(tcl.Tcl_Time{}))
+		p := in.tls.Alloc(sz)
+
+		defer in.tls.Free(sz)
+
+		setTime(p, sz, deadline.Unix(), int64(deadline.Nanosecond()/1000))
+		tcl.XTcl_LimitSetTime(in.tls, in.interp, p)
+		tcl.XTcl_LimitTypeSet(in.tls, in.interp, tcl.TCL_LIMIT_TIME)
+		return nil
+	})
+}
+
+// setTime stores sec and usec in the Tcl_Time of size sz at p.
+func setTime(p uintptr, sz int, sec, usec int64) {
+	// The Tcl_Time fields are C longs.
+	switch sz {
+	case 16:
+		*(*int64)(unsafe.Pointer(p)) = sec
+		*(*int64)(unsafe.Pointer(p + 8)) = usec
+	default:
+		*(*int32)(unsafe.Pointer(p)) = int32(sec)
+		*(*int32)(unsafe.Pointer(p + 4)) = int32(usec)
+	}
+}
+
+// getTime returns the seconds and microseconds of the Tcl_Time at p.
+func getTime(p uintptr) (sec, usec int64) {
+	switch unsafe.Sizeof(tcl.Tcl_Time{}) {
+	case 16:
+		return *(*int64)(unsafe.Pointer(p)), *(*int64)(unsafe.Pointer(p + 8))
+	default:
+		return int64(*(*int32)(unsafe.Pointer(p))), int64(*(*int32)(unsafe.Pointer(p + 4)))
+	}
+}
+
+// SetLimitGranularity sets how often the limit of type typ is checked. A
+// granularity of n checks the limit on every n-th opportunity. The default
+// granularity is 1 for LimitCommands and 10 for LimitTime.
+func (in *Interp) SetLimitGranularity(typ LimitType, n int) error {
+	if n < 1 {
+		return fmt.Errorf("granularity must be at least 1: %d", n)
+	}
+
+	return in.do(func() error {
+		tcl.XTcl_LimitSetGranularity(in.tls, in.interp, int32(typ), int32(n))
+		return nil
+	})
+}
+
+// RemoveLimit disables the limit of type typ.
+func (in *Interp) RemoveLimit(typ LimitType) error {
+	return in.do(func() error {
+		tcl.XTcl_LimitTypeReset(in.tls, in.interp, int32(typ))
+		return nil
+	})
+}
+
+// LimitHandler is called when the limit of type typ is exceeded. The handler
+// may raise or remove the limit to let the evaluation continue.
+type LimitHandler func(in *Interp, typ LimitType)
+
+// LimitCallback represents a LimitHandler added by AddLimitHandler.
+type LimitCallback struct {
+	h   uintptr
+	in  *Interp
+	f   LimitHandler
+	typ LimitType
+}
+
+var (
+	limitHandlerP = *(*uintptr)(unsafe.Pointer(&struct {
+		f func(tls *libc.TLS, clientData, interp uintptr)
+	}{limitHandler}))
+	limitDeleteP = *(*uintptr)(unsafe.Pointer(&struct {
+		f func(tls *libc.TLS, clientData uintptr)
+	}{limitDelete}))
+)
+
+func limitHandler(tls *libc.TLS, clientData, interp uintptr) {
+	c := getObject(clientData).(*LimitCallback)
+	c.in.callback(func() { c.f(c.in, c.typ) })
+}
+
+func limitDelete(tls *libc.TLS, clientData uintptr) {
+	getObject(clientData).(*LimitCallback).h = 0
+	removeObject(clientData)
+}
+
+// AddLimitHandler arranges for f to be called when the limit of type typ is
+// exceeded.
+func (in *Interp) AddLimitHandler(typ LimitType, f LimitHandler) (*LimitCallback, error) {
+	c := &LimitCallback{in: in, f: f, typ: typ}
+	if err := in.do(func() error {
+		c.h = addObject(c)
+		tcl.XTcl_LimitAddHandler(in.tls, in.interp, int32(typ), limitHandlerP, c.h, limitDeleteP)
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
+
+// MustAddLimitHandler is like AddLimitHandler but panics on error.
+func (in *Interp) MustAddLimitHandler(typ LimitType, f LimitHandler) *LimitCallback {
+	c, err := in.AddLimitHandler(typ, f)
+	if err != nil {
+		panic(err)
+	}
+
+	return c
+}
+
+// Remove removes the limit handler. Removing a handler that no longer exists,
+// including the handlers of a closed interpreter, is a no-op.
+func (c *LimitCallback) Remove() error {
+	err := c.in.do(func() error {
+		if c.h != 0 {
+			tcl.XTcl_LimitRemoveHandler(c.in.tls, c.in.interp, int32(c.typ), limitHandlerP, c.h)
+		}
+		return nil
+	})
+	if err == ErrClosed {
+		return nil
+	}
+
+	return err
+}
+
+// evalError returns the error of an evaluation that completed with return
+// code rc.
+func (in *Interp) evalError(rc int32) error {
+	err := in.newError(rc)
+	if tcl.XTcl_LimitExceeded(in.tls, in.interp) != 0 {
+		for _, typ := range []LimitType{LimitCommands, LimitTime} {
+			if tcl.XTcl_LimitTypeExceeded(in.tls, in.interp, int32(typ)) != 0 {
+				return &LimitError{Type: typ, Err: err}
+			}
+		}
+	}
+	return err
+}
@@ -0,0 +1,149 @@
+// Copyright 2026 The Tcl Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tcl // import "modernc.org/tcl"
+
+import (
+	"fmt"
+	"reflect"
+
+	"modernc.org/tcl/lib"
+)
+
+type linkVar struct {
+	in       *Interp
+	name     string
+	readOnly bool
+	trace    *VarTrace
+	v        reflect.Value // The pointed to Go variable.
+}
+
+// LinkVar keeps the global Tcl variable name synchronized with the Go
+// variable ptr points to, similarly to Tcl_LinkVar. Ptr must be a pointer to
+// a string, a boolean, an integer or a floating point number, for example
+// *int64, *float64, *bool or *string.
+//
+// Reading the Tcl variable returns the current value of the Go variable.
+// Writing the Tcl variable converts the new value to the type of the Go
+// variable and updates it, unless readOnly is true, in which case the write
+// fails. Unsetting the Tcl variable recreates it. The Go variable is never
+// passed to the Tcl C code, the synchronization is implemented by variable
+// traces.
+//
+// The Go variable must not be modified concurrently with the interpreter
+// evaluating scripts.
+func (in *Interp) LinkVar(name string, ptr interface{}, readOnly bool) error {
+	return in.do(func() error { return in.linkVar(name, ptr, readOnly) })
+}
+
+func (in *Interp) linkVar(name string, ptr interface{}, readOnly bool) error {
+	v := reflect.ValueOf(ptr)
+	if v.Kind() != reflect.Ptr || v.IsNil() {
+		return fmt.Errorf("expected a non nil pointer: %T", ptr)
+	}
+
+	switch v.Elem().Kind() {
+	case
+		reflect.Bool,
+		reflect.Float32, reflect.Float64,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.String,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		// ok
+	default:
+		return fmt.Errorf("unsupported linked variable type: %T", ptr)
+	}
+
+	if in.links[name] != nil {
+		if err := in.unlinkVar(name); err != nil {
+			return err
+		}
+	}
+
+	l := &linkVar{in: in, name: name, readOnly: readOnly, v: v.Elem()}
+	if err := l.link(); err != nil {
+		return err
+	}
+
+	if in.links == nil {
+		in.links = map[string]*linkVar{}
+	}
+	in.links[name] = l
+	return nil
+}
+
+// MustLinkVar is like LinkVar but panics on error.
+func (in *Interp) MustLinkVar(name string, ptr interface{}, readOnly bool) {
+	if err := in.LinkVar(name, ptr, readOnly); err != nil {
+		panic(err)
+	}
+}
+
+// UnlinkVar removes the link created by LinkVar. The Tcl variable keeps its
+// last value.
+func (in *Interp) UnlinkVar(name string) error {
+	return in.do(func() error { return in.unlinkVar(name) })
+}
+
+func (in *Interp) unlinkVar(name string) error {
+	l := in.links[name]
+	if l == nil {
+		return fmt.Errorf("variable is not linked: %s", name)
+	}
+
+	delete(in.links, name)
+	return l.trace.remove()
+}
+
+func (l *linkVar) link() (err error) {
+	if err = l.in.setVar(l.name, nil, l.v.Interface(), GlobalOnly); err != nil {
+		return err
+	}
+
+	l.trace, err = l.in.traceVar(l.name, TraceReads|TraceWrites|TraceUnsets|GlobalOnly, l.traceProc)
+	return err
+}
+
+func (l *linkVar) traceProc(op TraceOp, name1, name2 string) error {
+	// Trace procedures are Go code called by Tcl, see Interp.callback, the
+	// synchronization is a use of the interpreter of its own.
+	return l.in.do(func() error { return l.sync(op) })
+}
+
+// sync synchronizes the Go and Tcl variables on the operation op.
+func (l *linkVar) sync(op TraceOp) error {
+	switch op {
+	case TraceReads:
+		return l.in.setVar(l.name, nil, l.v.Interface(), GlobalOnly)
+	case TraceWrites:
+		if l.readOnly {
+			l.in.setVar(l.name, nil, l.v.Interface(), GlobalOnly)
+			return fmt.Errorf("linked variable is read-only")
+		}
+
+		o, err := l.in.getVar(l.name, nil, GlobalOnly)
+		if err != nil {
+			return err
+		}
+
+		v, err := fromObj(o, l.v.Type())
+		if err != nil {
+			l.in.setVar(l.name, nil, l.v.Interface(), GlobalOnly)
+			return fmt.Errorf("variable must have %s value", typeUsage(l.v.Type()))
+		}
+
+		l.v.Set(v)
+	case TraceUnsets:
+		if tcl.XTcl_InterpDeleted(l.in.tls, l.in.interp) != 0 || l.in.links[l.name] != l {
+			break
+		}
+
+		// The trace is being destroyed together with the variable.
+		// Recreate both as Tcl_LinkVar does.
+		if err := l.link(); err != nil {
+			delete(l.in.links, l.name)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,426 @@
+// Copyright 2026 The Tcl Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tcl // import "modernc.org/tcl"
+
+import (
+	"fmt"
+	"math/big"
+	"reflect"
+	"runtime"
+	"strings"
+
+	"modernc.org/tcl/lib"
+)
+
+var (
+	bigIntType = reflect.TypeOf((*big.Int)(nil))
+	errorType  = reflect.TypeOf((*error)(nil)).Elem()
+	interpType = reflect.TypeOf((*Interp)(nil))
+	objType    = reflect.TypeOf((*Obj)(nil))
+)
+
+// RegisterFunc creates a Tcl command name implemented by fn, which must be a
+// Go function. The Tcl arguments are converted to the types of the fn
+// parameters and the values returned by fn are converted back to a Tcl value.
+// If the first parameter of fn has type *Interp, it receives the interpreter
+// executing the command and it does not consume an argument. If the last
+// result of fn has type error and is not nil, the command fails with the
+// error text as its result.
+//
+// Supported parameter types are strings, booleans, integers, floating point
+// numbers, []byte, *big.Int, *Obj, slices and arrays (Tcl lists), maps and
+// structs (Tcl dictionaries) and pointers to any of those. Struct fields are
+// mapped to dictionary keys by their name or by the name given by a `tcl`
+// field tag. Values created by NewGoObj are passed as the wrapped Go value to
+// parameters of a type the value is assignable to. Multiple results, not
+// counting the trailing error, are returned as a Tcl list.
+func (in *Interp) RegisterFunc(name string, fn interface{}) (r *Command, err error) {
+	v := reflect.ValueOf(fn)
+	if v.Kind() != reflect.Func || v.IsNil() {
+		return nil, fmt.Errorf("expected a function: %T", fn)
+	}
+
+	// The arguments are passed as the Tcl values of objv, not as strings,
+	// the values created by NewGoObj would be lost otherwise.
+	err = in.do(func() error {
+		r, err = in.createObjCommand(name, &cmdProc{
+			fv: func(in *Interp, objv []*Obj) int32 { return in.callFunc(v, objv, 1) },
+			in: in,
+		})
+		return err
+	})
+	return r, err
+}
+
+// callFunc calls fn with the arguments following the first skip words of
+// objv, the command, converted as described in RegisterFunc and sets the
+// interpreter result to the converted results of fn. It returns the return
+// code of the command.
+func (in *Interp) callFunc(fn reflect.Value, objv []*Obj, skip int) int32 {
+	args := objv[skip:]
+	t := fn.Type()
+	first := 0
+	if t.NumIn() != 0 && t.In(0) == interpType {
+		first = 1
+	}
+	nout := t.NumOut()
+	hasErr := nout != 0 && t.Out(nout-1) == errorType
+	if hasErr {
+		nout--
+	}
+	nin := t.NumIn() - first
+	if len(args) < nin-1 || !t.IsVariadic() && len(args) != nin {
+		return in.wrongNumArgs(objv[:skip], funcUsage(t, first))
+	}
+
+	a := make([]reflect.Value, 0, first+len(args))
+	if first != 0 {
+		a = append(a, reflect.ValueOf(in))
+	}
+	for i, arg := range args {
+		var pt reflect.Type
+		switch j := first + i; {
+		case t.IsVariadic() && j >= t.NumIn()-1:
+			pt = t.In(t.NumIn() - 1).Elem()
+		default:
+			pt = t.In(j)
+		}
+		av, err := fromObj(arg, pt)
+		if err != nil {
+			return in.setError(err)
+		}
+
+		a = append(a, av)
+	}
+	var out []reflect.Value
+	in.callback(func() { out = fn.Call(a) })
+	if hasErr {
+		if err := out[nout].Interface(); err != nil {
+			return in.setError(err.(error))
+		}
+	}
+
+	// The result is set as a Tcl value, converting it to a string would lose
+	// for example the values created by NewGoObj.
+	var r *Obj
+	switch nout {
+	case 0:
+		return tcl.TCL_OK
+	case 1:
+		r = toObj(out[0])
+	default:
+		items := make([]*Obj, nout)
+		for i := range items {
+			items[i] = toObj(out[i])
+		}
+		r = NewListObj(items...)
+	}
+	tcl.XTcl_SetObjResult(in.tls, in.interp, r.p)
+	runtime.KeepAlive(r)
+	return tcl.TCL_OK
+}
+
+// MustRegisterFunc is like RegisterFunc but panics on error.
+func (in *Interp) MustRegisterFunc(name string, fn interface{}) *Command {
+	cmd, err := in.RegisterFunc(name, fn)
+	if err != nil {
+		panic(err)
+	}
+
+	return cmd
+}
+
+// funcUsage returns the usage of the arguments reported on a wrong number of
+// arguments.
+func funcUsage(t reflect.Type, first int) string {
+	var a []string
+	for i := first; i < t.NumIn(); i++ {
+		switch pt := t.In(i); {
+		case t.IsVariadic() && i == t.NumIn()-1:
+			a = append(a, fmt.Sprintf("?%s ...?", typeUsage(pt.Elem())))
+		default:
+			a = append(a, typeUsage(pt))
+		}
+	}
+	return strings.Join(a, " ")
+}
+
+func typeUsage(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return "bytes"
+		}
+
+		return "list"
+	case reflect.Map, reflect.Struct:
+		return "dict"
+	case reflect.Ptr:
+		if t == bigIntType {
+			return "integer"
+		}
+
+		if t == objType {
+			return "value"
+		}
+
+		return typeUsage(t.Elem())
+	case reflect.Bool:
+		return "boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return "integer"
+	case reflect.Float32, reflect.Float64:
+		return "double"
+	default:
+		return "string"
+	}
+}
+
+// fromObj converts o to a Go value of type t.
+func fromObj(o *Obj, t reflect.Type) (reflect.Value, error) {
+	r := reflect.New(t).Elem()
+	gv, ok := goObjValue(o.p)
+	if v := reflect.ValueOf(gv); ok && v.IsValid() && v.Type().AssignableTo(t) {
+		r.Set(v)
+		return r, nil
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		r.SetString(o.String())
+	case reflect.Interface:
+		if t.NumMethod() != 0 {
+			return r, fmt.Errorf("unsupported type: %s", t)
+		}
+
+		r.Set(reflect.ValueOf(o.String()))
+	case reflect.Bool:
+		b, err := o.Bool()
+		if err != nil {
+			return r, err
+		}
+
+		r.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := o.Int()
+		if err != nil {
+			return r, err
+		}
+
+		if r.OverflowInt(n) {
+			return r, fmt.Errorf("integer value too large to represent as %s: %d", t, n)
+		}
+
+		r.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		n, err := o.BigInt()
+		if err != nil {
+			return r, err
+		}
+
+		if n.Sign() < 0 || !n.IsUint64() || r.OverflowUint(n.Uint64()) {
+			return r, fmt.Errorf("integer value out of range for %s: %s", t, n)
+		}
+
+		r.SetUint(n.Uint64())
+	case reflect.Float32, reflect.Float64:
+		f, err := o.Double()
+		if err != nil {
+			return r, err
+		}
+
+		r.SetFloat(f)
+	case reflect.Slice:
+		if t.Elem().Kind() == reflect.Uint8 {
+			r.SetBytes(o.Bytes())
+			break
+		}
+
+		a, err := o.List()
+		if err != nil {
+			return r, err
+		}
+
+		r.Set(reflect.MakeSlice(t, len(a), len(a)))
+		for i, v := range a {
+			e, err := fromObj(v, t.Elem())
+			if err != nil {
+				return r, err
+			}
+
+			r.Index(i).Set(e)
+		}
+	case reflect.Array:
+		a, err := o.List()
+		if err != nil {
+			return r, err
+		}
+
+		if len(a) != t.Len() {
+			return r, fmt.Errorf("expected list of %d elements but got %d", t.Len(), len(a))
+		}
+
+		for i, v := range a {
+			e, err := fromObj(v, t.Elem())
+			if err != nil {
+				return r, err
+			}
+
+			r.Index(i).Set(e)
+		}
+	case reflect.Map:
+		m, err := o.Dict()
+		if err != nil {
+			return r, err
+		}
+
+		r.Set(reflect.MakeMapWithSize(t, len(m)))
+		for k, v := range m {
+			kv, err := fromObj(NewStringObj(k), t.Key())
+			if err != nil {
+				return r, err
+			}
+
+			e, err := fromObj(v, t.Elem())
+			if err != nil {
+				return r, err
+			}
+
+			r.SetMapIndex(kv, e)
+		}
+	case reflect.Struct:
+		m, err := o.Dict()
+		if err != nil {
+			return r, err
+		}
+
+		for i := 0; i < t.NumField(); i++ {
+			nm, ok := fieldName(t.Field(i))
+			if !ok {
+				continue
+			}
+
+			v, ok := m[nm]
+			if !ok {
+				continue
+			}
+
+			e, err := fromObj(v, t.Field(i).Type)
+			if err != nil {
+				return r, err
+			}
+
+			r.Field(i).Set(e)
+		}
+	case reflect.Ptr:
+		switch t {
+		case objType:
+			r.Set(reflect.ValueOf(o))
+		case bigIntType:
+			n, err := o.BigInt()
+			if err != nil {
+				return r, err
+			}
+
+			r.Set(reflect.ValueOf(n))
+		default:
+			e, err := fromObj(o, t.Elem())
+			if err != nil {
+				return r, err
+			}
+
+			r.Set(reflect.New(t.Elem()))
+			r.Elem().Set(e)
+		}
+	default:
+		return r, fmt.Errorf("unsupported type: %s", t)
+	}
+	return r, nil
+}
+
+// toObj converts v to a Tcl value.
+func toObj(v reflect.Value) *Obj {
+	if !v.IsValid() {
+		return NewStringObj("")
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		return NewStringObj(v.String())
+	case reflect.Bool:
+		return NewBoolObj(v.Bool())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return NewIntObj(v.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if n := v.Uint(); n > 1<<63-1 {
+			return NewBigIntObj(new(big.Int).SetUint64(n))
+		}
+
+		return NewIntObj(int64(v.Uint()))
+	case reflect.Float32, reflect.Float64:
+		return NewDoubleObj(v.Float())
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice && v.Type().Elem().Kind() == reflect.Uint8 {
+			return NewByteArrayObj(v.Bytes())
+		}
+
+		items := make([]*Obj, v.Len())
+		for i := range items {
+			items[i] = toObj(v.Index(i))
+		}
+		return NewListObj(items...)
+	case reflect.Map:
+		m := make(map[string]*Obj, v.Len())
+		for it := v.MapRange(); it.Next(); {
+			m[toObj(it.Key()).String()] = toObj(it.Value())
+		}
+		return NewDictObj(m)
+	case reflect.Struct:
+		m := map[string]*Obj{}
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			if nm, ok := fieldName(t.Field(i)); ok {
+				m[nm] = toObj(v.Field(i))
+			}
+		}
+		return NewDictObj(m)
+	case reflect.Ptr, reflect.Interface:
+		if v.IsNil() {
+			return NewStringObj("")
+		}
+
+		switch x := v.Interface().(type) {
+		case *Obj:
+			return x
+		case *big.Int:
+			return NewBigIntObj(x)
+		case error:
+			return NewStringObj(x.Error())
+		case fmt.Stringer:
+			return NewStringObj(x.String())
+		}
+
+		return toObj(v.Elem())
+	default:
+		return NewStringObj(fmt.Sprint(v.Interface()))
+	}
+}
+
+// fieldName returns the dictionary key of an exported struct field.
+func fieldName(f reflect.StructField) (string, bool) {
+	if f.PkgPath != "" {
+		return "", false
+	}
+
+	switch tag := f.Tag.Get("tcl"); tag {
+	case "-":
+		return "", false
+	case "":
+		return f.Name, true
+	default:
+		return tag, true
+	}
+}
@@ -0,0 +1,266 @@
+// Copyright 2026 The Tcl Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tcl // import "modernc.org/tcl"
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unsafe"
+
+	"modernc.org/libc"
+	"modernc.org/tcl/lib"
+)
+
+// Namespace represents a Tcl namespace. The namespace is looked up by its
+// fully qualified name every time it is used, so a Namespace remains valid,
+// but fails to be used, after the namespace is deleted.
+type Namespace struct {
+	in   *Interp
+	name string
+}
+
+// NewNamespace creates the namespace name and returns it or an error, if
+// any. Relative names are resolved in the current namespace, missing parent
+// namespaces are created as well. It is an error if the namespace already
+// exists.
+func (in *Interp) NewNamespace(name string) (r *Namespace, err error) {
+	err = in.do(func() error { r, err = in.newNamespace(name); return err })
+	return r, err
+}
+
+func (in *Interp) newNamespace(name string) (*Namespace, error) {
+	nm, err := libc.CString(name)
+	if err != nil {
+		return nil, err
+	}
+
+	defer libc.Xfree(in.tls, nm)
+
+	ns := tcl.XTcl_CreateNamespace(in.tls, in.interp, nm, 0, 0)
+	if ns == 0 {
+		return nil, in.newError(tcl.TCL_ERROR)
+	}
+
+	return &Namespace{in: in, name: namespaceName(ns)}, nil
+}
+
+// MustNewNamespace is like NewNamespace but panics on error.
+func (in *Interp) MustNewNamespace(name string) *Namespace {
+	ns, err := in.NewNamespace(name)
+	if err != nil {
+		panic(err)
+	}
+
+	return ns
+}
+
+// Namespace returns the existing namespace name or an error, if any.
+// Relative names are resolved in the current namespace.
+func (in *Interp) Namespace(name string) (r *Namespace, err error) {
+	err = in.do(func() error { r, err = in.namespace(name); return err })
+	return r, err
+}
+
+func (in *Interp) namespace(name string) (*Namespace, error) {
+	ns, err := in.findNamespace(name)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Namespace{in: in, name: namespaceName(ns)}, nil
+}
+
+// MustNamespace is like Namespace but panics on error.
+func (in *Interp) MustNamespace(name string) *Namespace {
+	ns, err := in.Namespace(name)
+	if err != nil {
+		panic(err)
+	}
+
+	return ns
+}
+
+func (in *Interp) findNamespace(name string) (uintptr, error) {
+	nm, err := libc.CString(name)
+	if err != nil {
+		return 0, err
+	}
+
+	defer libc.Xfree(in.tls, nm)
+
+	ns := tcl.XTcl_FindNamespace(in.tls, in.interp, nm, 0, tcl.TCL_LEAVE_ERR_MSG)
+	if ns == 0 {
+		return 0, in.newError(tcl.TCL_ERROR)
+	}
+
+	return ns, nil
+}
+
+// namespaceName returns the fully qualified name of the Tcl_Namespace at ns.
+func namespaceName(ns uintptr) string {
+	return libc.GoString((*tcl.Tcl_Namespace)(unsafe.Pointer(ns)).FfullName)
+}
+
+// Name returns the fully qualified name of ns.
+func (ns *Namespace) Name() string { return ns.name }
+
+// Export adds patterns to the export list of ns. Commands of ns matching any
+// of the patterns can be imported by other namespaces and become subcommands
+// of an ensemble created for ns. The patterns may contain glob characters but
+// no namespace qualifiers.
+func (ns *Namespace) Export(patterns ...string) error {
+	return ns.in.do(func() error { return ns.exportPatterns(patterns) })
+}
+
+func (ns *Namespace) exportPatterns(patterns []string) error {
+	in := ns.in
+	p, err := in.findNamespace(ns.name)
+	if err != nil {
+		return err
+	}
+
+	for _, v := range patterns {
+		if err := ns.export(p, v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (ns *Namespace) export(p uintptr, pattern string) error {
+	in := ns.in
+	pat, err := libc.CString(pattern)
+	if err != nil {
+		return err
+	}
+
+	defer libc.Xfree(in.tls, pat)
+
+	if tcl.XTcl_Export(in.tls, in.interp, p, pat, 0) != tcl.TCL_OK {
+		return in.newError(tcl.TCL_ERROR)
+	}
+
+	return nil
+}
+
+// Import imports into ns the commands matching pattern, which has the form
+// namespace::glob, for example ::app::db::*. Only commands exported by their
+// namespace are imported. Unless force is true, it is an error if an imported
+// command would replace an existing command of ns.
+func (ns *Namespace) Import(pattern string, force bool) error {
+	return ns.in.do(func() error { return ns.importCommands(pattern, force) })
+}
+
+func (ns *Namespace) importCommands(pattern string, force bool) error {
+	in := ns.in
+	p, err := in.findNamespace(ns.name)
+	if err != nil {
+		return err
+	}
+
+	pat, err := libc.CString(pattern)
+	if err != nil {
+		return err
+	}
+
+	defer libc.Xfree(in.tls, pat)
+
+	var overwrite int32
+	if force {
+		overwrite = 1
+	}
+	if tcl.XTcl_Import(in.tls, in.interp, p, pat, overwrite) != tcl.TCL_OK {
+		return in.newError(tcl.TCL_ERROR)
+	}
+
+	return nil
+}
+
+// Delete deletes ns together with its variables, commands and child
+// namespaces.
+func (ns *Namespace) Delete() error {
+	return ns.in.do(ns.delete)
+}
+
+func (ns *Namespace) delete() error {
+	p, err := ns.in.findNamespace(ns.name)
+	if err != nil {
+		return err
+	}
+
+	tcl.XTcl_DeleteNamespace(ns.in.tls, p)
+	return nil
+}
+
+// NewEnsemble creates the ensemble command name with the subcommands cmds
+// and returns it or an error, if any. The subcommands are created as the
+// exported commands of the namespace name, which is created if it does not
+// exist, so for example the subcommand query of the ensemble ::app::db can
+// also be invoked as ::app::db::query. Like for 'namespace ensemble create',
+// subcommands may be abbreviated to any unique prefix and an unknown
+// subcommand produces an error listing the valid ones. Commands exported by
+// the namespace by other means become subcommands of the ensemble as well.
+func (in *Interp) NewEnsemble(name string, cmds map[string]CmdProc) (r *Command, err error) {
+	err = in.do(func() error { r, err = in.newEnsemble(name, cmds); return err })
+	return r, err
+}
+
+func (in *Interp) newEnsemble(name string, cmds map[string]CmdProc) (*Command, error) {
+	names := make([]string, 0, len(cmds))
+	for k := range cmds {
+		if k == "" || strings.Contains(k, "::") {
+			return nil, fmt.Errorf("invalid subcommand name: %q", k)
+		}
+
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	ns, err := in.namespace(name)
+	if err != nil {
+		if ns, err = in.newNamespace(name); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, k := range names {
+		if _, err := in.newCommand(ns.name+"::"+k, cmds[k], nil, nil); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := ns.exportPatterns(names); err != nil {
+		return nil, err
+	}
+
+	p, err := in.findNamespace(ns.name)
+	if err != nil {
+		return nil, err
+	}
+
+	nm, err := libc.CString(ns.name)
+	if err != nil {
+		return nil, err
+	}
+
+	defer libc.Xfree(in.tls, nm)
+
+	cmd := tcl.XTcl_CreateEnsemble(in.tls, in.interp, nm, p, tcl.TCL_ENSEMBLE_PREFIX)
+	if cmd == 0 {
+		return nil, fmt.Errorf("failed to create ensemble: %s", name)
+	}
+
+	return &Command{cmd}, nil
+}
+
+// MustNewEnsemble is like NewEnsemble but panics on error.
+func (in *Interp) MustNewEnsemble(name string, cmds map[string]CmdProc) *Command {
+	cmd, err := in.NewEnsemble(name, cmds)
+	if err != nil {
+		panic(err)
+	}
+
+	return cmd
+}
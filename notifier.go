@@ -0,0 +1,251 @@
+// Copyright 2026 The Tcl Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tcl // import "modernc.org/tcl"
+
+import (
+	"runtime"
+	"time"
+	"unsafe"
+
+	"modernc.org/libc"
+	"modernc.org/tcl/lib"
+)
+
+// The Tcl notifier is implemented in Go and installed by Tcl_SetNotifier
+// before any interpreter is created. It replaces the transpiled select based
+// notifiers, which are thread aware only on linux/amd64 and cannot be woken
+// up by goroutines. Waiting for alerts and timeouts uses Go channels and
+// timers. Waiting for the file descriptors of file handlers is platform
+// specific, see waitFiles.
+
+// notifier is the notifier of a Tcl thread, ie. of the interpreters sharing a
+// TLS or, when Tcl is built without threads, of all interpreters.
+type notifier struct {
+	filePoller
+	channels      map[uintptr]*goChannel // Watched Go channels, used by the thread of the notifier only.
+	channelSource bool                   // The event source of channels is installed.
+	files         map[int32]*fileHandler // Used by the thread of the notifier only.
+	wake          chan struct{}          // Signalled by alert.
+}
+
+// fileHandler is a handler registered by Tcl_CreateFileHandler.
+type fileHandler struct {
+	clientData uintptr
+	mask       int32 // Events of interest.
+	proc       uintptr
+	ready      int32 // Events seen by waitFiles but not yet handled.
+}
+
+var (
+	notifierKey uintptr // Tcl_ThreadDataKey of the notifier handle.
+
+	notifierProcs = tcl.Tcl_NotifierProcs{
+		FsetTimerProc: *(*uintptr)(unsafe.Pointer(&struct {
+			f func(tls *libc.TLS, timePtr uintptr)
+		}{notifierSetTimer})),
+		FwaitForEventProc: *(*uintptr)(unsafe.Pointer(&struct {
+			f func(tls *libc.TLS, timePtr uintptr) int32
+		}{notifierWaitForEvent})),
+		FcreateFileHandlerProc: *(*uintptr)(unsafe.Pointer(&struct {
+			f func(tls *libc.TLS, fd, mask int32, proc, clientData uintptr)
+		}{notifierCreateFileHandler})),
+		FdeleteFileHandlerProc: *(*uintptr)(unsafe.Pointer(&struct {
+			f func(tls *libc.TLS, fd int32)
+		}{notifierDeleteFileHandler})),
+		FinitNotifierProc: *(*uintptr)(unsafe.Pointer(&struct {
+			f func(tls *libc.TLS) uintptr
+		}{notifierInit})),
+		FfinalizeNotifierProc: *(*uintptr)(unsafe.Pointer(&struct {
+			f func(tls *libc.TLS, clientData uintptr)
+		}{notifierFinalize})),
+		FalertNotifierProc: *(*uintptr)(unsafe.Pointer(&struct {
+			f func(tls *libc.TLS, clientData uintptr)
+		}{notifierAlert})),
+		FserviceModeHookProc: *(*uintptr)(unsafe.Pointer(&struct {
+			f func(tls *libc.TLS, mode int32)
+		}{notifierServiceModeHook})),
+	}
+
+	fileEventP = *(*uintptr)(unsafe.Pointer(&struct {
+		f func(tls *libc.TLS, evPtr uintptr, flags int32) int32
+	}{fileEvent}))
+)
+
+func init() {
+	tls := libc.NewTLS()
+
+	defer tls.Close()
+
+	tcl.XTcl_SetNotifier(tls, uintptr(unsafe.Pointer(&notifierProcs)))
+}
+
+// notifierHandle returns a pointer to the handle of the notifier of the
+// current Tcl thread.
+func notifierHandle(tls *libc.TLS) *uintptr {
+	return (*uintptr)(unsafe.Pointer(tcl.XTcl_GetThreadData(tls, uintptr(unsafe.Pointer(&notifierKey)), int32(unsafe.Sizeof(uintptr(0))))))
+}
+
+// currentNotifier returns the notifier of the current Tcl thread.
+func currentNotifier(tls *libc.TLS) *notifier {
+	return getObject(*notifierHandle(tls)).(*notifier)
+}
+
+// threaded reports whether Tcl is built with threads, ie. whether every root
+// interpreter has its own Tcl thread and notifier. The generator enables
+// threads only on linux/amd64.
+const threaded = runtime.GOOS == "linux" && runtime.GOARCH == "amd64"
+
+// alert wakes up the notifier if it is waiting for events or makes its next
+// wait return immediately. It may be called from any goroutine.
+func (n *notifier) alert() {
+	select {
+	case n.wake <- struct{}{}:
+		n.filePoller.alert()
+	default:
+		// Already alerted.
+	}
+}
+
+// wait waits for an alert or until timeout elapses. A negative timeout waits
+// forever.
+func (n *notifier) wait(timeout time.Duration) {
+	if timeout == 0 {
+		select {
+		case <-n.wake:
+		default:
+		}
+		return
+	}
+
+	var c <-chan time.Time
+	if timeout > 0 {
+		t := time.NewTimer(timeout)
+
+		defer t.Stop()
+
+		c = t.C
+	}
+	select {
+	case <-n.wake:
+	case <-c:
+	}
+}
+
+// notifierInit implements Tcl_InitNotifier.
+func notifierInit(tls *libc.TLS) uintptr {
+	p := notifierHandle(tls)
+	if *p == 0 {
+		n := &notifier{
+			channels: map[uintptr]*goChannel{},
+			files:    map[int32]*fileHandler{},
+			wake:     make(chan struct{}, 1),
+		}
+		n.filePoller.init()
+		*p = addObject(n)
+	}
+	return *p
+}
+
+// notifierFinalize implements Tcl_FinalizeNotifier. It is called by
+// Tcl_FinalizeThread when a root interpreter is closed, see Interp.Close.
+func notifierFinalize(tls *libc.TLS, clientData uintptr) {
+	n := getObject(clientData).(*notifier)
+	if n.channelSource {
+		tcl.XTcl_DeleteEventSource(tls, channelSetupP, channelCheckP, 0)
+	}
+	n.filePoller.close()
+	removeObject(clientData)
+	*notifierHandle(tls) = 0
+}
+
+// notifierAlert implements Tcl_AlertNotifier. It is called by Tcl_ThreadAlert,
+// possibly from another thread.
+func notifierAlert(tls *libc.TLS, clientData uintptr) {
+	getObject(clientData).(*notifier).alert()
+}
+
+// notifierSetTimer implements Tcl_SetTimer. Like in the unix notifier it
+// does nothing, the only event loop is Tcl_DoOneEvent, which passes the
+// timeout to Tcl_WaitForEvent.
+func notifierSetTimer(tls *libc.TLS, timePtr uintptr) {}
+
+// notifierServiceModeHook implements Tcl_ServiceModeHook.
+func notifierServiceModeHook(tls *libc.TLS, mode int32) {}
+
+// notifierWaitForEvent implements Tcl_WaitForEvent. It waits until a file
+// handler is ready, the notifier is alerted or the time at timePtr, if not
+// zero, elapses. Ready file handlers are queued as Tcl events.
+func notifierWaitForEvent(tls *libc.TLS, timePtr uintptr) int32 {
+	n := currentNotifier(tls)
+	timeout := time.Duration(-1)
+	if timePtr != 0 {
+		sec, usec := getTime(timePtr)
+		if timeout = time.Duration(sec)*time.Second + time.Duration(usec)*time.Microsecond; timeout < 0 {
+			timeout = 0
+		}
+	}
+	if len(n.files) == 0 {
+		n.wait(timeout)
+		return 0
+	}
+
+	return n.waitFiles(tls, timeout)
+}
+
+// notifierCreateFileHandler implements Tcl_CreateFileHandler.
+func notifierCreateFileHandler(tls *libc.TLS, fd, mask int32, proc, clientData uintptr) {
+	n := currentNotifier(tls)
+	h := n.files[fd]
+	if h == nil {
+		h = &fileHandler{}
+		n.files[fd] = h
+	}
+	h.clientData = clientData
+	h.mask = mask
+	h.proc = proc
+	n.watch(fd, mask)
+}
+
+// notifierDeleteFileHandler implements Tcl_DeleteFileHandler.
+func notifierDeleteFileHandler(tls *libc.TLS, fd int32) {
+	n := currentNotifier(tls)
+	n.unwatch(fd)
+	delete(n.files, fd)
+}
+
+// fileReady records that the events in mask occurred on fd and queues a Tcl
+// event calling the file handler, unless one is already queued.
+func (n *notifier) fileReady(tls *libc.TLS, fd, mask int32) {
+	h := n.files[fd]
+	if h == nil || mask&h.mask == 0 {
+		return
+	}
+
+	if h.ready == 0 {
+		queueEvent(tls, fileEventP, uintptr(fd))
+	}
+	h.ready = mask
+}
+
+// fileEvent calls the file handler of a file event queued by fileReady.
+func fileEvent(tls *libc.TLS, evPtr uintptr, flags int32) int32 {
+	if flags&FileEvents == 0 {
+		return 0
+	}
+
+	h := currentNotifier(tls).files[int32(eventData(evPtr))]
+	if h == nil { // The handler was deleted.
+		return 1
+	}
+
+	mask := h.ready & h.mask
+	h.ready = 0
+	if mask != 0 {
+		(*struct {
+			f func(tls *libc.TLS, clientData uintptr, mask int32)
+		})(unsafe.Pointer(&struct{ uintptr }{h.proc})).f(tls, h.clientData, mask)
+	}
+	return 1
+}
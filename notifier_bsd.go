@@ -0,0 +1,80 @@
+// Copyright 2026 The Tcl Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build darwin || freebsd || netbsd || openbsd
+// +build darwin freebsd netbsd openbsd
+
+package tcl // import "modernc.org/tcl"
+
+import (
+	"golang.org/x/sys/unix"
+	"modernc.org/tcl/lib"
+)
+
+// newQueue returns a new kqueue instance.
+func newQueue() (int, error) {
+	q, err := unix.Kqueue()
+	if err != nil {
+		return -1, err
+	}
+
+	unix.CloseOnExec(q)
+	return q, nil
+}
+
+// updateQueue changes the events watched on fd from old to mask. Zero stops
+// watching fd. Kqueue has no filter for exceptional conditions, TCL_EXCEPTION
+// is never reported.
+func updateQueue(q int, fd, old, mask int32) error {
+	var changes []unix.Kevent_t
+	for _, v := range []struct {
+		event  int32
+		filter int
+	}{
+		{tcl.TCL_READABLE, unix.EVFILT_READ},
+		{tcl.TCL_WRITABLE, unix.EVFILT_WRITE},
+	} {
+		var flags int
+		switch {
+		case mask&v.event != 0 && old&v.event == 0:
+			flags = unix.EV_ADD
+		case mask&v.event == 0 && old&v.event != 0:
+			flags = unix.EV_DELETE
+		default:
+			continue
+		}
+
+		var ev unix.Kevent_t
+		unix.SetKevent(&ev, int(fd), v.filter, flags)
+		changes = append(changes, ev)
+	}
+	if len(changes) == 0 {
+		return nil
+	}
+
+	_, err := unix.Kevent(q, changes, nil, &unix.Timespec{})
+	return err
+}
+
+// pollQueue reports whether a watched descriptor is ready, without waiting.
+// If f is not nil it is called with the events of every ready descriptor.
+func pollQueue(q int, f func(fd, mask int32)) bool {
+	var evs [64]unix.Kevent_t
+	n, err := unix.Kevent(q, nil, evs[:], &unix.Timespec{})
+	if err != nil || n <= 0 {
+		return false
+	}
+
+	if f != nil {
+		for _, v := range evs[:n] {
+			switch int(v.Filter) {
+			case unix.EVFILT_READ:
+				f(int32(v.Ident), tcl.TCL_READABLE)
+			case unix.EVFILT_WRITE:
+				f(int32(v.Ident), tcl.TCL_WRITABLE)
+			}
+		}
+	}
+	return true
+}
@@ -0,0 +1,64 @@
+// Copyright 2026 The Tcl Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tcl // import "modernc.org/tcl"
+
+import (
+	"golang.org/x/sys/unix"
+	"modernc.org/tcl/lib"
+)
+
+// newQueue returns a new epoll instance.
+func newQueue() (int, error) { return unix.EpollCreate1(unix.EPOLL_CLOEXEC) }
+
+// updateQueue changes the events watched on fd from old to mask. Zero stops
+// watching fd.
+func updateQueue(q int, fd, old, mask int32) error {
+	if mask == 0 {
+		return unix.EpollCtl(q, unix.EPOLL_CTL_DEL, int(fd), &unix.EpollEvent{})
+	}
+
+	ev := unix.EpollEvent{Fd: fd}
+	if mask&tcl.TCL_READABLE != 0 {
+		ev.Events |= unix.EPOLLIN
+	}
+	if mask&tcl.TCL_WRITABLE != 0 {
+		ev.Events |= unix.EPOLLOUT
+	}
+	if mask&tcl.TCL_EXCEPTION != 0 {
+		ev.Events |= unix.EPOLLPRI
+	}
+	op := unix.EPOLL_CTL_MOD
+	if old == 0 {
+		op = unix.EPOLL_CTL_ADD
+	}
+	return unix.EpollCtl(q, op, int(fd), &ev)
+}
+
+// pollQueue reports whether a watched descriptor is ready, without waiting.
+// If f is not nil it is called with the events of every ready descriptor.
+func pollQueue(q int, f func(fd, mask int32)) bool {
+	var evs [64]unix.EpollEvent
+	n, err := unix.EpollWait(q, evs[:], 0)
+	if err != nil || n <= 0 {
+		return false
+	}
+
+	if f != nil {
+		for _, v := range evs[:n] {
+			var mask int32
+			if v.Events&(unix.EPOLLIN|unix.EPOLLHUP|unix.EPOLLERR) != 0 {
+				mask |= tcl.TCL_READABLE
+			}
+			if v.Events&(unix.EPOLLOUT|unix.EPOLLERR) != 0 {
+				mask |= tcl.TCL_WRITABLE
+			}
+			if v.Events&unix.EPOLLPRI != 0 {
+				mask |= tcl.TCL_EXCEPTION
+			}
+			f(v.Fd, mask)
+		}
+	}
+	return true
+}
@@ -0,0 +1,176 @@
+// Copyright 2026 The Tcl Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build darwin || freebsd || linux || netbsd || openbsd
+// +build darwin freebsd linux netbsd openbsd
+
+package tcl // import "modernc.org/tcl"
+
+import (
+	"errors"
+	"os"
+	"sync"
+	"syscall"
+	"time"
+
+	"golang.org/x/sys/unix"
+	"modernc.org/libc"
+	"modernc.org/tcl/lib"
+)
+
+// aLongTimeAgo is a read deadline that has already passed.
+var aLongTimeAgo = time.Unix(1, 0)
+
+// filePoller waits for the file descriptors of file handlers using the Go
+// runtime poller. The descriptors are owned by Tcl channels, which may be in
+// blocking mode and are closed by Tcl, so they cannot be registered with the
+// runtime poller themselves. Instead they are watched by an epoll or kqueue
+// instance, whose descriptor is readable while a watched descriptor is ready
+// and is registered with the runtime poller. Waiting thus parks the goroutine
+// of the notifier instead of blocking an OS thread and alerts wake it up by
+// moving the read deadline. Descriptors that cannot be watched, like those of
+// regular files, are always ready, as reported by poll(2). The instance is
+// closed by notifierFinalize.
+type filePoller struct {
+	mu      sync.Mutex      // Guards q.
+	always  map[int32]bool  // Descriptors that cannot be watched.
+	q       *os.File        // The epoll or kqueue instance, nil until the first watch.
+	qfd     int             // The descriptor of q.
+	rc      syscall.RawConn // Of q.
+	watched map[int32]int32 // Watched descriptors and their events.
+}
+
+func (p *filePoller) init() {
+	p.always = map[int32]bool{}
+	p.watched = map[int32]int32{}
+}
+
+func (p *filePoller) alert() {
+	p.mu.Lock()
+
+	defer p.mu.Unlock()
+
+	if p.q != nil {
+		p.q.SetReadDeadline(aLongTimeAgo)
+	}
+}
+
+func (p *filePoller) close() {
+	p.mu.Lock()
+
+	defer p.mu.Unlock()
+
+	if p.q != nil {
+		p.q.Close()
+		p.q = nil
+		p.rc = nil
+	}
+}
+
+// open creates the epoll or kqueue instance if necessary.
+func (p *filePoller) open() error {
+	p.mu.Lock()
+
+	defer p.mu.Unlock()
+
+	if p.q != nil {
+		return nil
+	}
+
+	fd, err := newQueue()
+	if err != nil {
+		return err
+	}
+
+	if err := unix.SetNonblock(fd, true); err != nil {
+		unix.Close(fd)
+		return err
+	}
+
+	// os.NewFile registers a non-blocking descriptor with the runtime poller.
+	// Fd must not be called on q, it would switch it back to blocking mode.
+	q := os.NewFile(uintptr(fd), "tcl-notifier")
+	rc, err := q.SyscallConn()
+	if err != nil {
+		q.Close()
+		return err
+	}
+
+	p.q = q
+	p.qfd = fd
+	p.rc = rc
+	return nil
+}
+
+// watch starts or updates watching fd for the events in mask.
+func (p *filePoller) watch(fd, mask int32) {
+	if p.always[fd] {
+		return
+	}
+
+	if err := p.open(); err == nil {
+		old := p.watched[fd]
+		if err = updateQueue(p.qfd, fd, old, mask); err == nil {
+			p.watched[fd] = mask
+			return
+		}
+
+		if old != 0 {
+			updateQueue(p.qfd, fd, old, 0)
+		}
+	}
+
+	delete(p.watched, fd)
+	p.always[fd] = true
+}
+
+// unwatch stops watching fd. It must be called before fd is closed, the
+// descriptor may be reused by a new file.
+func (p *filePoller) unwatch(fd int32) {
+	if old, ok := p.watched[fd]; ok {
+		updateQueue(p.qfd, fd, old, 0)
+		delete(p.watched, fd)
+	}
+	delete(p.always, fd)
+}
+
+// waitFiles is like wait but also waits until a file handler is ready. Ready
+// file handlers are queued as Tcl events.
+func (n *notifier) waitFiles(tls *libc.TLS, timeout time.Duration) int32 {
+	p := &n.filePoller
+	if len(p.always) != 0 {
+		timeout = 0
+	}
+	if p.rc == nil {
+		n.wait(timeout)
+	} else if timeout != 0 {
+		var deadline time.Time
+		if timeout > 0 {
+			deadline = time.Now().Add(timeout)
+		}
+		// The deadline is set before checking for an alert. Alerts signal wake
+		// before moving the deadline, so none is lost.
+		p.q.SetReadDeadline(deadline)
+		select {
+		case <-n.wake:
+			// Already alerted, only collect the ready file handlers.
+		default:
+			if err := p.rc.Read(func(uintptr) bool { return pollQueue(p.qfd, nil) }); err != nil && !errors.Is(err, os.ErrDeadlineExceeded) {
+				return -1
+			}
+		}
+	}
+
+	if p.rc != nil {
+		pollQueue(p.qfd, func(fd, mask int32) { n.fileReady(tls, fd, mask) })
+	}
+	for fd := range p.always {
+		n.fileReady(tls, fd, tcl.TCL_READABLE|tcl.TCL_WRITABLE)
+	}
+	select {
+	case <-n.wake:
+	default:
+	}
+	return 0
+}
@@ -0,0 +1,27 @@
+// Copyright 2026 The Tcl Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tcl // import "modernc.org/tcl"
+
+import (
+	"time"
+
+	"modernc.org/libc"
+)
+
+// filePoller does nothing on Windows. Tcl channels do not use file handlers
+// there, their helper threads use Tcl_ThreadAlert, which alerts the notifier.
+type filePoller struct{}
+
+func (p *filePoller) init()                {}
+func (p *filePoller) alert()               {}
+func (p *filePoller) close()               {}
+func (p *filePoller) watch(fd, mask int32) {}
+func (p *filePoller) unwatch(fd int32)     {}
+
+// waitFiles is like wait.
+func (n *notifier) waitFiles(tls *libc.TLS, timeout time.Duration) int32 {
+	n.wait(timeout)
+	return 0
+}
@@ -0,0 +1,362 @@
+// Copyright 2026 The Tcl Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tcl // import "modernc.org/tcl"
+
+import (
+	"fmt"
+	"math/big"
+	"runtime"
+	"sort"
+	"sync"
+	"unsafe"
+
+	"modernc.org/libc"
+	"modernc.org/tcl/lib"
+)
+
+var (
+	objReleased releaseQueue // Of the Objs created using the Obj TLS.
+	objTLS      *libc.TLS    // Used by Obj methods, guarded by objTLSMu.
+	objTLSMu    sync.Mutex
+)
+
+// releaseQueue collects the Tcl_Obj pointers of unreachable Objs owned by a
+// TLS. Tcl reference counts are not atomic, so only the goroutine using the
+// owning TLS may give up the references.
+type releaseQueue struct {
+	mu     sync.Mutex
+	closed bool // The owning TLS is closed.
+	ptrs   []uintptr
+}
+
+// Obj represents a Tcl value, ie. a Tcl_Obj.
+//
+// The reference count of the underlying Tcl_Obj is incremented when the Obj is
+// created. Once the Obj becomes unreachable, the reference is given up by the
+// owner of the Obj: the interpreter that created it, at its next evaluation,
+// or, for Objs created by the Obj constructors and methods, the next call of
+// an Obj constructor or method. An Obj may be shared by interpreters, but not
+// concurrently.
+type Obj struct {
+	p uintptr
+}
+
+// newOwnedObj returns an Obj holding a reference to the Tcl_Obj at p. The
+// reference is given up by the owner of q.
+func newOwnedObj(q *releaseQueue, p uintptr) *Obj {
+	incrRefCount(p)
+	o := &Obj{p}
+	runtime.SetFinalizer(o, func(o *Obj) {
+		q.mu.Lock()
+		if !q.closed {
+			q.ptrs = append(q.ptrs, o.p)
+		}
+		q.mu.Unlock()
+	})
+	return o
+}
+
+// release gives up the references held by the unreachable Objs in q.
+func (q *releaseQueue) release(tls *libc.TLS) {
+	q.mu.Lock()
+	a := q.ptrs
+	q.ptrs = nil
+	q.mu.Unlock()
+	for _, p := range a {
+		decrRefCount(tls, p)
+	}
+}
+
+// close gives up the references held by the unreachable Objs in q and
+// discards the Objs becoming unreachable later. Their references are never
+// given up, the TLS may be gone, so their Tcl_Objs leak.
+func (q *releaseQueue) close(tls *libc.TLS) {
+	q.release(tls)
+	q.mu.Lock()
+	q.closed = true
+	q.ptrs = nil
+	q.mu.Unlock()
+}
+
+// newObj returns an Obj owned by the Obj TLS holding a reference to the
+// Tcl_Obj at p. It must be called by the holder of objTLSMu.
+func newObj(p uintptr) *Obj { return newOwnedObj(&objReleased, p) }
+
+// newObj returns an Obj owned by the interpreter holding a reference to the
+// Tcl_Obj at p.
+func (in *Interp) newObj(p uintptr) *Obj { return newOwnedObj(&in.root().released, p) }
+
+// releaseObjects gives up the references held by the unreachable Objs owned
+// by the interpreter.
+func (in *Interp) releaseObjects() { in.root().released.release(in.tls) }
+
+// withObjTLS runs f with exclusive access to the package Obj TLS.
+func withObjTLS(f func(tls *libc.TLS)) {
+	objTLSMu.Lock()
+
+	defer objTLSMu.Unlock()
+
+	if objTLS == nil {
+		objTLS = libc.NewTLS()
+	}
+	objReleased.release(objTLS)
+	f(objTLS)
+}
+
+// newStringObj returns a newly created Tcl_Obj with a zero reference count.
+func newStringObj(tls *libc.TLS, s string) uintptr {
+	p := tls.Alloc(len(s) + 1)
+
+	defer tls.Free(len(s) + 1)
+
+	copy((*libc.RawMem)(unsafe.Pointer(p))[:len(s):len(s)], s)
+	return tcl.XTcl_NewStringObj(tls, p, int32(len(s)))
+}
+
+// NewStringObj returns a newly created Obj with the value s.
+func NewStringObj(s string) (r *Obj) {
+	withObjTLS(func(tls *libc.TLS) { r = newObj(newStringObj(tls, s)) })
+	return r
+}
+
+// NewIntObj returns a newly created Obj with the value n.
+func NewIntObj(n int64) (r *Obj) {
+	withObjTLS(func(tls *libc.TLS) { r = newObj(tcl.XTcl_NewWideIntObj(tls, n)) })
+	return r
+}
+
+// NewDoubleObj returns a newly created Obj with the value f.
+func NewDoubleObj(f float64) (r *Obj) {
+	withObjTLS(func(tls *libc.TLS) { r = newObj(tcl.XTcl_NewDoubleObj(tls, f)) })
+	return r
+}
+
+// NewBoolObj returns a newly created Obj with the value b.
+func NewBoolObj(b bool) (r *Obj) {
+	var n int32
+	if b {
+		n = 1
+	}
+	withObjTLS(func(tls *libc.TLS) { r = newObj(tcl.XTcl_NewBooleanObj(tls, n)) })
+	return r
+}
+
+// NewByteArrayObj returns a newly created Obj with the value b.
+func NewByteArrayObj(b []byte) (r *Obj) {
+	withObjTLS(func(tls *libc.TLS) {
+		p := tls.Alloc(len(b) + 1)
+
+		defer tls.Free(len(b) + 1)
+
+		copy((*libc.RawMem)(unsafe.Pointer(p))[:len(b):len(b)], b)
+		r = newObj(tcl.XTcl_NewByteArrayObj(tls, p, int32(len(b))))
+	})
+	return r
+}
+
+// NewBigIntObj returns a newly created Obj with the value n.
+func NewBigIntObj(n *big.Int) (r *Obj) {
+	withObjTLS(func(tls *libc.TLS) {
+		s := n.Text(16)
+		sz := int(unsafe.Sizeof(tcl.Mp_int{})) + len(s) + 1
+		bp := tls.Alloc(sz)
+
+		defer tls.Free(sz)
+
+		str := bp + unsafe.Sizeof(tcl.Mp_int{})
+		copy((*libc.RawMem)(unsafe.Pointer(str))[:len(s):len(s)], s)
+		*(*byte)(unsafe.Pointer(str + uintptr(len(s)))) = 0
+		if tcl.XTclBN_mp_init(tls, bp) != tcl.MP_OKAY {
+			panic(todo("out of memory"))
+		}
+
+		if tcl.XTclBN_mp_read_radix(tls, bp, str, 16) != tcl.MP_OKAY {
+			tcl.XTclBN_mp_clear(tls, bp)
+			panic(todo("out of memory"))
+		}
+
+		// Tcl_NewBignumObj takes ownership of the mp_int digits.
+		r = newObj(tcl.XTcl_NewBignumObj(tls, bp))
+	})
+	return r
+}
+
+// NewListObj returns a newly created Tcl list of items.
+func NewListObj(items ...*Obj) (r *Obj) {
+	withObjTLS(func(tls *libc.TLS) {
+		list := tcl.XTcl_NewListObj(tls, 0, 0)
+		for _, v := range items {
+			tcl.XTcl_ListObjAppendElement(tls, 0, list, v.p)
+		}
+		r = newObj(list)
+	})
+	runtime.KeepAlive(items)
+	return r
+}
+
+// NewDictObj returns a newly created Tcl dictionary of m. The keys are added
+// in sorted order.
+func NewDictObj(m map[string]*Obj) (r *Obj) {
+	var keys []string
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	withObjTLS(func(tls *libc.TLS) {
+		dict := tcl.XTcl_NewDictObj(tls)
+		for _, k := range keys {
+			tcl.XTcl_DictObjPut(tls, 0, dict, newStringObj(tls, k), m[k].p)
+		}
+		r = newObj(dict)
+	})
+	runtime.KeepAlive(m)
+	return r
+}
+
+// Handle returns the Tcl_Obj pointer of o. It is used when calling libtcl
+// directly. The pointer is valid only while o is reachable.
+func (o *Obj) Handle() uintptr { return o.p }
+
+// String returns the string representation of o.
+func (o *Obj) String() (r string) {
+	withObjTLS(func(tls *libc.TLS) { r = objString(tls, o.p) })
+	runtime.KeepAlive(o)
+	return r
+}
+
+// Int returns the integer value of o or an error, if any.
+func (o *Obj) Int() (r int64, err error) {
+	withObjTLS(func(tls *libc.TLS) {
+		bp := tls.Alloc(8)
+
+		defer tls.Free(8)
+
+		if tcl.XTcl_GetWideIntFromObj(tls, 0, o.p, bp) != tcl.TCL_OK {
+			err = fmt.Errorf("expected integer but got %q", objString(tls, o.p))
+			return
+		}
+
+		r = *(*int64)(unsafe.Pointer(bp))
+	})
+	runtime.KeepAlive(o)
+	return r, err
+}
+
+// Double returns the floating point value of o or an error, if any.
+func (o *Obj) Double() (r float64, err error) {
+	withObjTLS(func(tls *libc.TLS) {
+		bp := tls.Alloc(8)
+
+		defer tls.Free(8)
+
+		if tcl.XTcl_GetDoubleFromObj(tls, 0, o.p, bp) != tcl.TCL_OK {
+			err = fmt.Errorf("expected floating-point number but got %q", objString(tls, o.p))
+			return
+		}
+
+		r = *(*float64)(unsafe.Pointer(bp))
+	})
+	runtime.KeepAlive(o)
+	return r, err
+}
+
+// Bool returns the boolean value of o or an error, if any.
+func (o *Obj) Bool() (r bool, err error) {
+	withObjTLS(func(tls *libc.TLS) {
+		bp := tls.Alloc(4)
+
+		defer tls.Free(4)
+
+		if tcl.XTcl_GetBooleanFromObj(tls, 0, o.p, bp) != tcl.TCL_OK {
+			err = fmt.Errorf("expected boolean value but got %q", objString(tls, o.p))
+			return
+		}
+
+		r = *(*int32)(unsafe.Pointer(bp)) != 0
+	})
+	runtime.KeepAlive(o)
+	return r, err
+}
+
+// Bytes returns the byte array value of o.
+func (o *Obj) Bytes() (r []byte) {
+	withObjTLS(func(tls *libc.TLS) {
+		bp := tls.Alloc(4)
+
+		defer tls.Free(4)
+
+		p := tcl.XTcl_GetByteArrayFromObj(tls, o.p, bp)
+		r = libc.GoBytes(p, int(*(*int32)(unsafe.Pointer(bp))))
+	})
+	runtime.KeepAlive(o)
+	return r
+}
+
+// BigInt returns the integer value of o, of any magnitude, or an error, if
+// any.
+func (o *Obj) BigInt() (r *big.Int, err error) {
+	withObjTLS(func(tls *libc.TLS) {
+		sz := int(unsafe.Sizeof(tcl.Mp_int{})) + 8
+		bp := tls.Alloc(sz)
+
+		defer tls.Free(sz)
+
+		if tcl.XTcl_GetBignumFromObj(tls, 0, o.p, bp) != tcl.TCL_OK {
+			err = fmt.Errorf("expected integer but got %q", objString(tls, o.p))
+			return
+		}
+
+		defer tcl.XTclBN_mp_clear(tls, bp)
+
+		size := bp + unsafe.Sizeof(tcl.Mp_int{})
+		if tcl.XTclBN_mp_radix_size(tls, bp, 16, size) != tcl.MP_OKAY {
+			panic(todo("out of memory"))
+		}
+
+		n := int(*(*int32)(unsafe.Pointer(size)))
+		str := tls.Alloc(n)
+
+		defer tls.Free(n)
+
+		if tcl.XTclBN_mp_to_radix(tls, bp, str, tcl.Size_t(n), 0, 16) != tcl.MP_OKAY {
+			panic(todo("out of memory"))
+		}
+
+		r, _ = new(big.Int).SetString(libc.GoString(str), 16)
+	})
+	runtime.KeepAlive(o)
+	return r, err
+}
+
+// List returns the elements of o or an error, if any.
+func (o *Obj) List() (r []*Obj, err error) {
+	withObjTLS(func(tls *libc.TLS) {
+		var a []uintptr
+		if a, err = listElements(tls, 0, o.p); err != nil {
+			return
+		}
+
+		r = make([]*Obj, len(a))
+		for i, v := range a {
+			r[i] = newObj(v)
+		}
+	})
+	runtime.KeepAlive(o)
+	return r, err
+}
+
+// Dict returns the key-value pairs of o or an error, if any.
+func (o *Obj) Dict() (r map[string]*Obj, err error) {
+	withObjTLS(func(tls *libc.TLS) {
+		m := map[string]*Obj{}
+		if err = dictForEach(tls, 0, o.p, func(k, v uintptr) { m[objString(tls, k)] = newObj(v) }); err != nil {
+			return
+		}
+
+		r = m
+	})
+	runtime.KeepAlive(o)
+	return r, err
+}
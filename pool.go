@@ -0,0 +1,360 @@
+// Copyright 2026 The Tcl Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tcl // import "modernc.org/tcl"
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"modernc.org/tcl/lib"
+)
+
+// poolSnapshot is a lambda returning the state of an interpreter restored by
+// poolReset: the global variables except env, the global commands, the
+// procedures and the child namespaces of the global namespace.
+const poolSnapshot = `{} {
+	set procArgs {{p} {
+		set r {}
+		foreach a [info args $p] {
+			if {[info default $p $a d]} {lappend r [list $a $d]} else {lappend r $a}
+		}
+		set r
+	}}
+	set vars {}
+	foreach v [info globals] {
+		if {$v eq "env"} {
+			continue
+		}
+
+		if {[array exists ::$v]} {
+			lappend vars $v 1 [array get ::$v]
+		} elseif {[info exists ::$v]} {
+			lappend vars $v 0 [set ::$v]
+		}
+	}
+	set procs {}
+	foreach p [info procs ::*] {
+		lappend procs $p [apply $procArgs $p] [info body $p]
+	}
+	list $vars [info commands ::*] $procs [namespace children ::]
+}`
+
+// poolReset is a lambda restoring the state returned by poolSnapshot. It
+// fails if a command present in the snapshot was deleted, renamed or replaced
+// by a procedure.
+const poolReset = `{snapshot} {
+	set procArgs {{p} {
+		set r {}
+		foreach a [info args $p] {
+			if {[info default $p $a d]} {lappend r [list $a $d]} else {lappend r $a}
+		}
+		set r
+	}}
+	lassign $snapshot vars cmds procs nss
+	foreach id [after info] {
+		after cancel $id
+	}
+	foreach ns [namespace children ::] {
+		if {$ns ni $nss} {
+			namespace delete $ns
+		}
+	}
+	foreach c [info commands ::*] {
+		if {$c ni $cmds} {
+			rename $c {}
+		}
+	}
+	foreach c $cmds {
+		if {[info commands $c] eq {}} {
+			error "command $c was deleted"
+		}
+	}
+	set procNames {}
+	foreach {p a body} $procs {
+		lappend procNames $p
+	}
+	foreach p [info procs ::*] {
+		if {$p ni $procNames} {
+			error "command $p was replaced"
+		}
+	}
+	foreach {p a body} $procs {
+		if {[info procs $p] eq {} || [info body $p] ne $body || [apply $procArgs $p] ne $a} {
+			proc $p $a $body
+		}
+	}
+	set keep {env {}}
+	foreach {v isArray value} $vars {
+		dict set keep $v {}
+	}
+	foreach v [info globals] {
+		if {![dict exists $keep $v]} {
+			unset -nocomplain ::$v
+		}
+	}
+	foreach {v isArray value} $vars {
+		if {$isArray} {
+			if {[array exists ::$v] && [array get ::$v] eq $value} {
+				continue
+			}
+
+			unset -nocomplain ::$v
+			array set ::$v $value
+			continue
+		}
+
+		if {[array exists ::$v]} {
+			unset ::$v
+		}
+		if {![info exists ::$v] || [set ::$v] ne $value} {
+			set ::$v $value
+		}
+	}
+}`
+
+// poolSnapshotObj holds the state of a pooled interpreter. The reset lambda is
+// kept per interpreter so its bytecode is reused.
+type poolSnapshotObj struct {
+	reset    *Obj
+	snapshot *Obj
+}
+
+// Pool is a fixed size set of interpreters that can be used by concurrently
+// running goroutines, one interpreter per goroutine at a time. Pool methods
+// are safe for concurrent use.
+//
+// Interpreters returned to the pool are reset to the state they had after
+// the init function passed to NewPool returned: global variables and
+// procedures are restored, global commands and namespaces created later are
+// deleted, pending 'after' events are canceled and resource limits are
+// removed. Interpreters that cannot be reset or that fail the health check
+// are closed and replaced by new ones.
+type Pool struct {
+	check     func(*Interp) error
+	done      chan struct{}
+	free      chan *Interp // Nil items stand for interpreters to create.
+	init      func(*Interp) error
+	mu        sync.Mutex
+	snapshots map[*Interp]*poolSnapshotObj
+
+	closed bool
+}
+
+// NewPool returns a newly created Pool of size interpreters or an error, if
+// any. If init is not nil, it is called for every new interpreter and can be
+// used, for example, to register commands and to source scripts.
+func NewPool(size int, init func(in *Interp) error) (*Pool, error) {
+	if size <= 0 {
+		return nil, fmt.Errorf("invalid pool size: %d", size)
+	}
+
+	p := &Pool{
+		done:      make(chan struct{}),
+		free:      make(chan *Interp, size),
+		init:      init,
+		snapshots: map[*Interp]*poolSnapshotObj{},
+	}
+	for i := 0; i < size; i++ {
+		in, err := p.newInterp()
+		if err != nil {
+			p.Close()
+			return nil, err
+		}
+
+		p.free <- in
+	}
+	return p, nil
+}
+
+// MustNewPool is like NewPool but panics on error.
+func MustNewPool(size int, init func(in *Interp) error) *Pool {
+	p, err := NewPool(size, init)
+	if err != nil {
+		panic(err)
+	}
+
+	return p
+}
+
+// SetHealthCheck sets the function used to check an interpreter returned to
+// the pool after it was reset. An interpreter for which f returns a non nil
+// error is closed and replaced by a new one. Passing nil removes the health
+// check.
+func (p *Pool) SetHealthCheck(f func(in *Interp) error) {
+	p.mu.Lock()
+	p.check = f
+	p.mu.Unlock()
+}
+
+func (p *Pool) newInterp() (*Interp, error) {
+	in, err := NewInterp()
+	if err != nil {
+		return nil, err
+	}
+
+	if p.init != nil {
+		if err := p.init(in); err != nil {
+			in.Close()
+			return nil, err
+		}
+	}
+
+	var s *poolSnapshotObj
+	if err := in.do(func() error {
+		rc := in.evalObjv([]*Obj{NewStringObj("apply"), NewStringObj(poolSnapshot)}, tcl.TCL_EVAL_GLOBAL)
+		if rc != tcl.TCL_OK {
+			return in.newError(rc)
+		}
+
+		s = &poolSnapshotObj{
+			reset:    NewStringObj(poolReset),
+			snapshot: in.newObj(tcl.XTcl_GetObjResult(in.tls, in.interp)),
+		}
+		return nil
+	}); err != nil {
+		in.Close()
+		return nil, err
+	}
+
+	p.mu.Lock()
+	p.snapshots[in] = s
+	p.mu.Unlock()
+	return in, nil
+}
+
+// Get returns an interpreter from the pool, waiting until one is available,
+// or an error, if any. The interpreter must be returned to the pool by Put.
+func (p *Pool) Get(ctx context.Context) (*Interp, error) {
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-p.done:
+		return nil, fmt.Errorf("pool closed")
+	case in := <-p.free:
+		if p.isClosed() {
+			if in != nil {
+				p.discard(in)
+			}
+			return nil, fmt.Errorf("pool closed")
+		}
+
+		if in != nil {
+			return in, nil
+		}
+
+		in, err := p.newInterp()
+		if err != nil {
+			p.free <- nil
+			return nil, err
+		}
+
+		return in, nil
+	}
+}
+
+// Put resets in and returns it to the pool. It must have been obtained by Get
+// and must not be used afterwards. An interpreter that was closed is
+// discarded and later replaced by a new one.
+func (p *Pool) Put(in *Interp) {
+	p.mu.Lock()
+	s, ok := p.snapshots[in]
+	check := p.check
+	p.mu.Unlock()
+	if !ok {
+		panic(fmt.Errorf("interpreter does not belong to the pool"))
+	}
+
+	if p.reset(in, s) != nil || check != nil && check(in) != nil {
+		p.discard(in)
+		in = nil
+	}
+
+	p.mu.Lock()
+	if !p.closed {
+		p.free <- in // Never blocks, the channel has room for all interpreters.
+		in = nil
+	}
+	p.mu.Unlock()
+	if in != nil {
+		p.discard(in)
+	}
+}
+
+func (p *Pool) isClosed() bool {
+	p.mu.Lock()
+
+	defer p.mu.Unlock()
+
+	return p.closed
+}
+
+// With calls f with an interpreter obtained by Get and returns it to the pool
+// when f returns. It returns the error of Get or f.
+func (p *Pool) With(ctx context.Context, f func(in *Interp) error) error {
+	in, err := p.Get(ctx)
+	if err != nil {
+		return err
+	}
+
+	defer p.Put(in)
+
+	return f(in)
+}
+
+// reset restores the state of in recorded in s. It returns ErrClosed if in
+// was closed.
+func (p *Pool) reset(in *Interp, s *poolSnapshotObj) error {
+	return in.do(func() error {
+		for _, typ := range []LimitType{LimitCommands, LimitTime} {
+			tcl.XTcl_LimitTypeReset(in.tls, in.interp, int32(typ))
+		}
+		rc := in.evalObjv([]*Obj{NewStringObj("apply"), s.reset, s.snapshot}, tcl.TCL_EVAL_GLOBAL)
+		if rc != tcl.TCL_OK {
+			return in.newError(rc)
+		}
+
+		tcl.XTcl_ResetResult(in.tls, in.interp)
+		return nil
+	})
+}
+
+func (p *Pool) discard(in *Interp) {
+	p.mu.Lock()
+	delete(p.snapshots, in)
+	p.mu.Unlock()
+	in.Close()
+}
+
+// Close closes the idle interpreters of the pool. Interpreters in use are
+// closed when they are returned by Put. Get fails after Close.
+func (p *Pool) Close() error {
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return nil
+	}
+
+	p.closed = true
+	close(p.done)
+	p.mu.Unlock()
+	for {
+		select {
+		case in := <-p.free:
+			if in != nil {
+				p.discard(in)
+			}
+		default:
+			return nil
+		}
+	}
+}
+
+// MustClose is like Close but panics on error.
+func (p *Pool) MustClose() {
+	if err := p.Close(); err != nil {
+		panic(err)
+	}
+}
@@ -0,0 +1,348 @@
+// Copyright 2026 The Tcl Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tcl // import "modernc.org/tcl"
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unsafe"
+
+	"modernc.org/libc"
+	"modernc.org/tcl/lib"
+)
+
+// profileFrame is an entry of the Tcl call stack recorded by a profiler.
+type profileFrame struct {
+	level int32  // Command nesting level.
+	name  string // Fully qualified command name.
+	file  string // File containing the call of the command, if known.
+	line  int64  // Line of the call of the command, if known.
+}
+
+// profileSample holds the values recorded for a call stack.
+type profileSample struct {
+	stack []profileFrame // Outermost frame first.
+	calls int64
+	wall  int64 // Nanoseconds.
+}
+
+// profiler records the commands executed by an interpreter.
+type profiler struct {
+	busy    bool // Set while the profiler evaluates 'info frame'.
+	depth   int  // Nesting of Go initiated evaluations.
+	h       uintptr
+	in      *Interp
+	last    time.Time // Zero when idle.
+	samples map[string]*profileSample
+	stack   []profileFrame
+	start   time.Time
+	trace   uintptr
+	w       io.Writer
+}
+
+var (
+	profileTraceP = *(*uintptr)(unsafe.Pointer(&struct {
+		f func(tls *libc.TLS, clientData, interp uintptr, level int32, command, token uintptr, objc int32, objv uintptr) int32
+	}{profileTrace}))
+	profileTraceDeleteP = *(*uintptr)(unsafe.Pointer(&struct {
+		f func(tls *libc.TLS, clientData uintptr)
+	}{removeHandle}))
+)
+
+// StartProfile starts profiling the commands executed by the interpreter and
+// returns an error, if any. The profile is written to w in the protobuf
+// format of pprof when StopProfile is called, so 'go tool pprof' can be used
+// to examine it.
+//
+// The profile records, for every call stack of Tcl commands, the number of
+// calls and the wall time spent. Stack frames are the fully qualified names
+// of the commands, procedures included, with the file and line of the calls
+// where 'info frame' reports them. Time spent outside of evaluations started
+// from Go is not recorded. Profiling disables the inline compilation of
+// commands to bytecode, which slows down the interpreter.
+func (in *Interp) StartProfile(w io.Writer) error {
+	return in.do(func() error { return in.startProfile(w) })
+}
+
+func (in *Interp) startProfile(w io.Writer) error {
+	if in.profile != nil {
+		return fmt.Errorf("profiling already in progress")
+	}
+
+	p := &profiler{
+		in:      in,
+		samples: map[string]*profileSample{},
+		start:   time.Now(),
+		w:       w,
+	}
+	p.h = addObject(p)
+	p.trace = tcl.XTcl_CreateObjTrace(in.tls, in.interp, 0, 0, profileTraceP, p.h, profileTraceDeleteP)
+	in.profile = p
+	return nil
+}
+
+// MustStartProfile is like StartProfile but panics on error.
+func (in *Interp) MustStartProfile(w io.Writer) {
+	if err := in.StartProfile(w); err != nil {
+		panic(err)
+	}
+}
+
+// StopProfile stops profiling started by StartProfile and writes the profile.
+// It returns an error, if any.
+func (in *Interp) StopProfile() error {
+	return in.do(in.stopProfile)
+}
+
+func (in *Interp) stopProfile() error {
+	p := in.profile
+	if p == nil {
+		return fmt.Errorf("profiling not in progress")
+	}
+
+	in.profile = nil
+	p.record(time.Now())
+	tcl.XTcl_DeleteTrace(in.tls, in.interp, p.trace)
+	return p.write(time.Now())
+}
+
+// MustStopProfile is like StopProfile but panics on error.
+func (in *Interp) MustStopProfile() {
+	if err := in.StopProfile(); err != nil {
+		panic(err)
+	}
+}
+
+func profileTrace(tls *libc.TLS, clientData, interp uintptr, level int32, command, token uintptr, objc int32, objv uintptr) int32 {
+	p := getObject(clientData).(*profiler)
+	if p.busy {
+		return tcl.TCL_OK
+	}
+
+	p.record(time.Now())
+	for len(p.stack) != 0 && p.stack[len(p.stack)-1].level >= level {
+		p.stack = p.stack[:len(p.stack)-1]
+	}
+	f := profileFrame{level: level, name: p.commandName(token)}
+	f.file, f.line = p.location()
+	p.stack = append(p.stack, f)
+	p.sample().calls++
+	p.last = time.Now() // Exclude the profiler overhead.
+	return tcl.TCL_OK
+}
+
+// commandName returns the fully qualified name of the command token.
+func (p *profiler) commandName(token uintptr) string {
+	tls := p.in.tls
+	o := tcl.XTcl_NewObj(tls)
+	incrRefCount(o)
+
+	defer decrRefCount(tls, o)
+
+	tcl.XTcl_GetCommandFullName(tls, p.in.interp, token, o)
+	return objString(tls, o)
+}
+
+// location returns the file and line of the command about to be executed as
+// reported by 'info frame'.
+func (p *profiler) location() (file string, line int64) {
+	p.busy = true
+
+	defer func() { p.busy = false }()
+
+	d, err := p.in.frame(0)
+	if err != nil {
+		return "", 0
+	}
+
+	line, _ = strconv.ParseInt(d["line"], 10, 64)
+	return d["file"], line
+}
+
+// record attributes the time elapsed since the last event to the current call
+// stack.
+func (p *profiler) record(now time.Time) {
+	if p.last.IsZero() || len(p.stack) == 0 {
+		return
+	}
+
+	p.sample().wall += int64(now.Sub(p.last))
+	p.last = now
+}
+
+// sample returns the sample of the current call stack.
+func (p *profiler) sample() *profileSample {
+	var b strings.Builder
+	for _, v := range p.stack {
+		fmt.Fprintf(&b, "%s\x00%s\x00%d\x00", v.name, v.file, v.line)
+	}
+	k := b.String()
+	s := p.samples[k]
+	if s == nil {
+		s = &profileSample{stack: append([]profileFrame(nil), p.stack...)}
+		p.samples[k] = s
+	}
+	return s
+}
+
+// enterEval is called when an evaluation started from Go begins.
+func (p *profiler) enterEval() {
+	p.depth++
+	if p.last.IsZero() {
+		p.last = time.Now()
+	}
+}
+
+// leaveEval is called when an evaluation started from Go ends.
+func (p *profiler) leaveEval() {
+	p.record(time.Now())
+	if p.depth--; p.depth == 0 {
+		p.last = time.Time{}
+		p.stack = p.stack[:0]
+	}
+}
+
+// write writes the profile in the pprof format.
+func (p *profiler) write(now time.Time) error {
+	index := map[string]int64{"": 0}
+	var table []string
+	str := func(s string) int64 {
+		if n, ok := index[s]; ok {
+			return n
+		}
+
+		n := int64(len(index))
+		index[s] = n
+		table = append(table, s)
+		return n
+	}
+	type locKey struct {
+		fn   uint64
+		line int64
+	}
+	funcs := map[string]uint64{}
+	locs := map[locKey]uint64{}
+	var profile, fns, locations protobuf
+	valueType := func(typ, unit string) []byte {
+		var b protobuf
+		b.int64(1, str(typ))
+		b.int64(2, str(unit))
+		return b.data
+	}
+	profile.bytes(1, valueType("calls", "count"))
+	profile.bytes(1, valueType("wall", "nanoseconds"))
+	keys := make([]string, 0, len(p.samples))
+	for k := range p.samples {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	// The file of a procedure is known from the calls made by its body.
+	files := map[string]string{}
+	for _, s := range p.samples {
+		for i, v := range s.stack[1:] {
+			if v.file != "" {
+				files[s.stack[i].name] = v.file
+			}
+		}
+	}
+	for _, k := range keys {
+		s := p.samples[k]
+		ids := make([]uint64, len(s.stack))
+		for i, v := range s.stack {
+			// The location of a frame is the call of the next inner frame.
+			var line int64
+			if i+1 < len(s.stack) {
+				line = s.stack[i+1].line
+			}
+			fn, ok := funcs[v.name]
+			if !ok {
+				fn = uint64(len(funcs) + 1)
+				funcs[v.name] = fn
+				var b protobuf
+				b.uint64(1, fn)
+				b.int64(2, str(v.name))
+				b.int64(3, str(v.name))
+				b.int64(4, str(files[v.name]))
+				fns.bytes(5, b.data)
+			}
+			lk := locKey{fn, line}
+			id, ok := locs[lk]
+			if !ok {
+				id = uint64(len(locs) + 1)
+				locs[lk] = id
+				var ln, b protobuf
+				ln.uint64(1, fn)
+				ln.int64(2, line)
+				b.uint64(1, id)
+				b.bytes(4, ln.data)
+				locations.bytes(4, b.data)
+			}
+			ids[len(ids)-1-i] = id // Leaf first.
+		}
+		var b protobuf
+		b.packed(1, ids)
+		b.packed(2, []uint64{uint64(s.calls), uint64(s.wall)})
+		profile.bytes(2, b.data)
+	}
+	profile.data = append(profile.data, locations.data...)
+	profile.data = append(profile.data, fns.data...)
+	profile.string(6, "")
+	for _, v := range table {
+		profile.string(6, v)
+	}
+	profile.int64(9, p.start.UnixNano())
+	profile.int64(10, int64(now.Sub(p.start)))
+	var buf bytes.Buffer
+	z := gzip.NewWriter(&buf)
+	z.Write(profile.data)
+	if err := z.Close(); err != nil {
+		return err
+	}
+
+	_, err := p.w.Write(buf.Bytes())
+	return err
+}
+
+// protobuf is a minimal protocol buffers encoder.
+type protobuf struct {
+	data []byte
+}
+
+func (b *protobuf) varint(x uint64) {
+	for x >= 0x80 {
+		b.data = append(b.data, byte(x)|0x80)
+		x >>= 7
+	}
+	b.data = append(b.data, byte(x))
+}
+
+func (b *protobuf) uint64(tag int, x uint64) {
+	b.varint(uint64(tag)<<3 | 0)
+	b.varint(x)
+}
+
+func (b *protobuf) int64(tag int, x int64) { b.uint64(tag, uint64(x)) }
+
+func (b *protobuf) bytes(tag int, data []byte) {
+	b.varint(uint64(tag)<<3 | 2)
+	b.varint(uint64(len(data)))
+	b.data = append(b.data, data...)
+}
+
+func (b *protobuf) string(tag int, s string) { b.bytes(tag, []byte(s)) }
+
+func (b *protobuf) packed(tag int, x []uint64) {
+	var p protobuf
+	for _, v := range x {
+		p.varint(v)
+	}
+	b.bytes(tag, p.data)
+}
@@ -0,0 +1,136 @@
+// Copyright 2026 The Tcl Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tcl // import "modernc.org/tcl"
+
+import (
+	"sort"
+	"unsafe"
+
+	"modernc.org/libc"
+	"modernc.org/tcl/lib"
+)
+
+// Script is a script compiled to bytecode by Compile. The bytecode is kept
+// with the script and reused by every evaluation, unless the interpreter
+// state it depends on changes, for example when a command it uses is
+// redefined. In that case the script is transparently recompiled.
+type Script struct {
+	in  *Interp
+	obj *Obj
+}
+
+// Compile compiles script to bytecode and returns the resulting Script or an
+// error, if any. Syntax errors of the commands of script, including incomplete
+// commands, are reported as an *Error. Errors in the bodies of commands, for
+// example of a 'foreach', are reported only when the Script is evaluated, like
+// for Interp.Eval. The Script can be evaluated only in the interpreter in.
+func (in *Interp) Compile(script string) (s *Script, err error) {
+	err = in.do(func() error { s, err = in.compile(script); return err })
+	return s, err
+}
+
+func (in *Interp) compile(script string) (*Script, error) {
+	cs, err := libc.CString(script)
+	if err != nil {
+		return nil, err
+	}
+
+	defer libc.Xfree(in.tls, cs)
+
+	if err := in.checkSyntax(cs, len(script)); err != nil {
+		return nil, err
+	}
+
+	in.releaseObjects()
+	obj := in.newObj(newStringObj(in.tls, script))
+	tcl.XTclCompileObj(in.tls, in.interp, obj.p, 0, 0)
+	return &Script{in: in, obj: obj}, nil
+}
+
+// checkSyntax parses the commands of the script of n bytes at p and returns
+// the first syntax error, if any. Tcl compiles commands with syntax errors to
+// bytecode raising the error, so only parsing reveals them before evaluation.
+func (in *Interp) checkSyntax(p uintptr, n int) error {
+	sz := int(unsafe.Sizeof(tcl.Tcl_Parse{}))
+	parse := in.tls.Alloc(sz)
+
+	defer in.tls.Free(sz)
+
+	for end := p + uintptr(n); p < end; {
+		if rc := tcl.XTcl_ParseCommand(in.tls, in.interp, p, int32(end-p), 0, parse); rc != tcl.TCL_OK {
+			return in.newError(rc)
+		}
+
+		parsed := (*tcl.Tcl_Parse)(unsafe.Pointer(parse))
+		p = parsed.FcommandStart + uintptr(parsed.FcommandSize)
+		tcl.XTcl_FreeParse(in.tls, parse)
+	}
+	return nil
+}
+
+// MustCompile is like Compile but panics on error.
+func (in *Interp) MustCompile(script string) *Script {
+	s, err := in.Compile(script)
+	if err != nil {
+		panic(err)
+	}
+
+	return s
+}
+
+// String returns the source of s.
+func (s *Script) String() string { return s.obj.String() }
+
+// Eval evaluates s and returns the interpreter result and error, if any, like
+// Interp.Eval does.
+func (s *Script) Eval() (r string, err error) {
+	in := s.in
+	err = in.do(func() error {
+		r, err = in.evalFunc(func() int32 { return tcl.XTcl_EvalObjEx(in.tls, in.interp, s.obj.p, 0) })
+		return err
+	})
+	return r, err
+}
+
+// MustEval is like Eval but panics on error.
+func (s *Script) MustEval() string {
+	r, err := s.Eval()
+	if err != nil {
+		panic(err)
+	}
+
+	return r
+}
+
+// EvalWith is like Eval but first sets the variables named by the keys of
+// vars to the respective values, converted to Tcl values as described in
+// RegisterFunc. The variables are set at the current level, which is the
+// global level unless EvalWith is called from a command implemented in Go. The
+// variables remain set after the evaluation.
+func (s *Script) EvalWith(vars map[string]interface{}) (string, error) {
+	in := s.in
+	var err error
+	eval := func() int32 {
+		names := make([]string, 0, len(vars))
+		for k := range vars {
+			names = append(names, k)
+		}
+		sort.Strings(names)
+		for _, k := range names {
+			if err = in.setVar(k, nil, vars[k], 0); err != nil {
+				return tcl.TCL_ERROR
+			}
+		}
+
+		return tcl.XTcl_EvalObjEx(in.tls, in.interp, s.obj.p, 0)
+	}
+	var r string
+	evalErr := in.do(func() (err error) { r, err = in.evalFunc(eval); return err })
+	if err != nil {
+		return "", err
+	}
+
+	return r, evalErr
+}
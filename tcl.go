@@ -51,6 +51,7 @@ import (
 	"path/filepath"
 	"runtime"
 	"sort"
+	"strconv"
 	"strings"
 	"sync"
 	"sync/atomic"
@@ -193,10 +194,50 @@ func Library(directory string) error {
 	return nil
 }
 
+// Error is returned when a Tcl evaluation completes with a return code other
+// than TCL_OK.
+type Error struct {
+	// Code is the return code, ie. TCL_ERROR, TCL_RETURN, TCL_BREAK,
+	// TCL_CONTINUE or a custom application defined value.
+	Code int
+	// Msg is the interpreter result at the time the evaluation completed.
+	Msg string
+	// ErrorCode is the -errorcode return option as a list.
+	ErrorCode []string
+	// ErrorInfo is the -errorinfo return option, ie. the Tcl stack trace.
+	ErrorInfo string
+	// ErrorLine is the -errorline return option or zero if not available.
+	ErrorLine int
+	// Options holds the complete return options dictionary as returned by
+	// Tcl_GetReturnOptions.
+	Options map[string]string
+}
+
+// Error implements error.
+func (e *Error) Error() string {
+	if e.Code == tcl.TCL_ERROR {
+		return e.Msg
+	}
+
+	if e.Msg == "" {
+		return fmt.Sprintf("return code: %d", e.Code)
+	}
+
+	return fmt.Sprintf("return code: %d: %s", e.Code, e.Msg)
+}
+
 // CmdProc is a Tcl command implemented in Go.
 type CmdProc func(clientData interface{}, in *Interp, args []string) int
 
-// DeleteProc is a function called when CmdProc is deleted.
+// ObjCmdProc is a Tcl command implemented in Go operating on Tcl values. The
+// first element of args is the command name. If ObjCmdProc returns a nil
+// error, the command completes with TCL_OK and the returned Obj, if not nil,
+// becomes the interpreter result. An *Error return value sets the return code
+// and -errorcode, any other non nil error completes the command with TCL_ERROR
+// using the error text as the result.
+type ObjCmdProc func(clientData interface{}, in *Interp, args []*Obj) (*Obj, error)
+
+// DeleteProc is a function called when CmdProc or ObjCmdProc is deleted.
 type DeleteProc func(clientData interface{})
 
 // Command represents a Tcl command.
@@ -206,8 +247,24 @@ type Command struct {
 
 // Interp represents a Tcl interpreter.
 type Interp struct {
-	tls    *libc.TLS
-	interp uintptr
+	active    int        // Uses in progress, see enter, guarded by guard, root interpreter only.
+	aliases   []uintptr  // Parent commands implementing aliases of a child.
+	callbacks int        // Go code called by Tcl in progress, see callback, guarded by guard, root interpreter only.
+	cond      *sync.Cond // Signals changes of active and callbacks, root interpreter only.
+	exec      *executor  // Non nil for bound interpreters.
+	guard     sync.Mutex
+	links     map[string]*linkVar
+	parent    *Interp
+	postMu    sync.Mutex
+	posted    []postedFunc          // Guarded by postMu, used by the root interpreter only.
+	profile   *profiler             // Non nil while profiling.
+	released  releaseQueue          // Root interpreter only.
+	source    uintptr               // Handle of the event source of Post, root interpreter only.
+	stdio     map[int32]*stdChannel // Keyed by TCL_STDIN etc., root interpreter only.
+	thread    uintptr               // Tcl_ThreadId of the root interpreter, see Post.
+	timers    map[*Timer]struct{}   // Pending timers created by After, root interpreter only.
+	tls       *libc.TLS
+	interp    uintptr
 }
 
 // NewInterp returns a newly created Interp or an error, if any.
@@ -236,7 +293,10 @@ func NewInterp() (*Interp, error) {
 		return nil, fmt.Errorf("failed to create Tcl interpreter")
 	}
 
-	return &Interp{tls, interp}, nil
+	in := &Interp{tls: tls, interp: interp, thread: tcl.XTcl_GetCurrentThread(tls)}
+	in.cond = sync.NewCond(&in.guard)
+	in.createEventSource()
+	return in, nil
 }
 
 // MustNewInterp is like NewInterp but panics on error.
@@ -279,10 +339,37 @@ func (in *Interp) TLS() *libc.TLS { return in.tls }
 
 // Close invalidates the interpreter and releases all its associated resources.
 func (in *Interp) Close() error {
+	return in.do(in.close)
+}
+
+func (in *Interp) close() error {
+	if in.parent != nil {
+		// Child interpreters share the TLS of their parent.
+		if in.interp != 0 {
+			in.stopTimers()
+			tcl.XTcl_DeleteInterp(in.tls, in.interp)
+		}
+		in.tls = nil
+		in.interp = 0
+		return nil
+	}
+
+	in.stopTimers()
+	in.restoreStdio()
+	in.deleteEventSource()
+	in.released.close(in.tls)
 	tcl.XTcl_DeleteInterp(in.tls, in.interp)
+	if threaded {
+		// Release the notifier and the other data of the Tcl thread of the
+		// TLS. Without threads all interpreters share them.
+		tcl.XTcl_FinalizeThread(in.tls)
+	}
 	in.tls.Close()
 	in.tls = nil
 	in.interp = 0
+	if in.exec != nil {
+		in.exec.stop()
+	}
 	return nil
 }
 
@@ -294,26 +381,56 @@ func (in *Interp) MustClose() {
 }
 
 // Eval evaluates script and returns the interpreter; result and error, if any.
+// A non nil error is of type *Error or, when the evaluation was aborted by a
+// resource limit, of type *LimitError. Eval returns ErrConcurrentUse if the
+// interpreter is being used by another goroutine.
 func (in *Interp) Eval(script string) (string, error) {
-	s, err := libc.CString(script)
+	return in.evalString(script, func(s uintptr, n int32) int32 { return tcl.XTcl_Eval(in.tls, in.interp, s) })
+}
+
+// evalString passes s, converted to a C string, and its length to eval, which
+// performs the evaluation, and returns the interpreter result and error, if
+// any, like Eval does.
+func (in *Interp) evalString(s string, eval func(s uintptr, n int32) int32) (r string, err error) {
+	err = in.do(func() error { r, err = in.evalScript(s, eval); return err })
+	return r, err
+}
+
+// evalScript implements evalString for callers already using the
+// interpreter, see do.
+func (in *Interp) evalScript(s string, eval func(s uintptr, n int32) int32) (string, error) {
+	cs, err := libc.CString(s)
 	if err != nil {
 		return "", err
 	}
 
+	defer libc.Xfree(in.tls, cs)
+
+	return in.evalFunc(func() int32 { return eval(cs, int32(len(s))) })
+}
+
+// evalFunc calls eval, which performs an evaluation and returns its return
+// code, and returns the interpreter result and error, if any, like Eval does.
+// The caller must be using the interpreter, see do.
+func (in *Interp) evalFunc(eval func() int32) (r string, err error) {
 	tcl.XTcl_Preserve(in.tls, in.interp)
 
-	defer func() {
-		libc.Xfree(in.tls, s)
-		tcl.XTcl_Release(in.tls, in.interp)
-	}()
+	defer tcl.XTcl_Release(in.tls, in.interp)
 
-	rc := tcl.XTcl_Eval(in.tls, in.interp, s)
-	rs := libc.GoString(tcl.XTcl_GetStringResult(in.tls, in.interp))
+	in.releaseObjects()
+	if p := in.profile; p != nil {
+		p.enterEval()
+
+		defer p.leaveEval()
+	}
+
+	rc := eval()
+	r = libc.GoString(tcl.XTcl_GetStringResult(in.tls, in.interp))
 	if rc == tcl.TCL_OK {
-		return rs, nil
+		return r, nil
 	}
 
-	return rs, fmt.Errorf("return code: %d", rc)
+	return r, in.evalError(rc)
 }
 
 // MustEval is like Eval but panics on error.
@@ -330,6 +447,8 @@ type cmdProc struct {
 	clientData interface{}
 	del        DeleteProc
 	f          CmdProc
+	fo         ObjCmdProc
+	fv         func(in *Interp, objv []*Obj) int32 // Sets the result itself and returns the return code.
 	in         *Interp
 }
 
@@ -341,13 +460,86 @@ func runCmd(tls *libc.TLS, clientData, in uintptr, argc int32, argv uintptr) int
 		argv += unsafe.Sizeof(argv)
 		a = append(a, libc.GoString(p))
 	}
-	return int32(cmd.f(cmd.clientData, cmd.in, a))
+	var rc int
+	cmd.in.callback(func() { rc = cmd.f(cmd.clientData, cmd.in, a) })
+	return int32(rc)
+}
+
+func runObjCmd(tls *libc.TLS, clientData, in uintptr, objc int32, objv uintptr) int32 {
+	cmd := getObject(clientData).(*cmdProc)
+	a := make([]*Obj, objc)
+	for i := range a {
+		a[i] = cmd.in.newObj(*(*uintptr)(unsafe.Pointer(objv))) //TODOOK
+		objv += unsafe.Sizeof(objv)
+	}
+	if cmd.fv != nil {
+		return cmd.fv(cmd.in, a)
+	}
+
+	var r *Obj
+	var err error
+	cmd.in.callback(func() { r, err = cmd.fo(cmd.clientData, cmd.in, a) })
+	if err != nil {
+		return cmd.in.setError(err)
+	}
+
+	if r != nil {
+		tcl.XTcl_SetObjResult(tls, in, r.p)
+	}
+	return tcl.TCL_OK
+}
+
+// setError sets the interpreter result and -errorcode according to err and
+// returns the corresponding return code.
+func (in *Interp) setError(err error) int32 {
+	rc := int32(tcl.TCL_ERROR)
+	msg := err.Error()
+	e, ok := err.(*Error)
+	if ok {
+		rc = int32(e.Code)
+		msg = e.Msg
+	}
+	tcl.XTcl_SetObjResult(in.tls, in.interp, newStringObj(in.tls, msg))
+	if ok && len(e.ErrorCode) != 0 {
+		list := tcl.XTcl_NewListObj(in.tls, 0, 0)
+		for _, v := range e.ErrorCode {
+			tcl.XTcl_ListObjAppendElement(in.tls, 0, list, newStringObj(in.tls, v))
+		}
+		tcl.XTcl_SetObjErrorCode(in.tls, in.interp, list)
+	}
+	return rc
+}
+
+// wrongNumArgs sets the interpreter result to the error reported by commands
+// called with a wrong number of arguments, built by Tcl_WrongNumArgs from the
+// command words in objv and the usage of the arguments in message, and
+// returns TCL_ERROR.
+func (in *Interp) wrongNumArgs(objv []*Obj, message string) int32 {
+	sz := len(objv) * int(ptrSize)
+	p := in.tls.Alloc(sz)
+
+	defer in.tls.Free(sz)
+
+	for i, v := range objv {
+		*(*uintptr)(unsafe.Pointer(p + uintptr(i)*ptrSize)) = v.p
+	}
+	var msg uintptr
+	if message != "" {
+		var err error
+		if msg, err = libc.CString(message); err != nil {
+			return in.setError(err)
+		}
+
+		defer libc.Xfree(in.tls, msg)
+	}
+	tcl.XTcl_WrongNumArgs(in.tls, in.interp, int32(len(objv)), p, msg)
+	return tcl.TCL_ERROR
 }
 
 func delCmd(tls *libc.TLS, clientData uintptr) {
 	cmd := getObject(clientData).(*cmdProc)
 	if cmd.del != nil {
-		cmd.del(cmd.clientData)
+		cmd.in.callback(func() { cmd.del(cmd.clientData) })
 	}
 	removeObject(clientData)
 }
@@ -356,13 +548,21 @@ var (
 	runCmdP = *(*uintptr)(unsafe.Pointer(&struct {
 		f func(tls *libc.TLS, clientData, in uintptr, argc int32, argv uintptr) int32
 	}{runCmd}))
+	runObjCmdP = *(*uintptr)(unsafe.Pointer(&struct {
+		f func(tls *libc.TLS, clientData, in uintptr, objc int32, objv uintptr) int32
+	}{runObjCmd}))
 	delCmdP = *(*uintptr)(unsafe.Pointer(&struct {
 		f func(tls *libc.TLS, clientData uintptr)
 	}{delCmd}))
 )
 
 // NewCommand returns a newly created Tcl command or an error, if any.
-func (in *Interp) NewCommand(name string, proc CmdProc, clientData interface{}, del DeleteProc) (*Command, error) {
+func (in *Interp) NewCommand(name string, proc CmdProc, clientData interface{}, del DeleteProc) (r *Command, err error) {
+	err = in.do(func() error { r, err = in.newCommand(name, proc, clientData, del); return err })
+	return r, err
+}
+
+func (in *Interp) newCommand(name string, proc CmdProc, clientData interface{}, del DeleteProc) (*Command, error) {
 	nm, err := libc.CString(name)
 	if err != nil {
 		return nil, err
@@ -395,8 +595,56 @@ func (in *Interp) MustNewCommand(name string, proc CmdProc, clientData interface
 	return cmd
 }
 
+// NewObjCommand returns a newly created Tcl command operating on Tcl values
+// or an error, if any.
+func (in *Interp) NewObjCommand(name string, proc ObjCmdProc, clientData interface{}, del DeleteProc) (r *Command, err error) {
+	err = in.do(func() error { r, err = in.newObjCommand(name, proc, clientData, del); return err })
+	return r, err
+}
+
+func (in *Interp) newObjCommand(name string, proc ObjCmdProc, clientData interface{}, del DeleteProc) (*Command, error) {
+	return in.createObjCommand(name, &cmdProc{fo: proc, clientData: clientData, del: del, in: in})
+}
+
+// createObjCommand creates the Tcl command name implemented by p.
+func (in *Interp) createObjCommand(name string, p *cmdProc) (*Command, error) {
+	nm, err := libc.CString(name)
+	if err != nil {
+		return nil, err
+	}
+
+	tcl.XTcl_Preserve(in.tls, in.interp)
+
+	defer func() {
+		libc.Xfree(in.tls, nm)
+		tcl.XTcl_Release(in.tls, in.interp)
+	}()
+
+	h := addObject(p)
+	cmd := tcl.XTcl_CreateObjCommand(in.tls, in.interp, nm, runObjCmdP, h, delCmdP)
+	if cmd == 0 {
+		return nil, fmt.Errorf("failed to create command: %s", name)
+	}
+
+	return &Command{cmd}, nil
+}
+
+// MustNewObjCommand is like NewObjCommand but panics on error.
+func (in *Interp) MustNewObjCommand(name string, proc ObjCmdProc, clientData interface{}, del DeleteProc) *Command {
+	cmd, err := in.NewObjCommand(name, proc, clientData, del)
+	if err != nil {
+		panic(err)
+	}
+
+	return cmd
+}
+
 // SetResult sets the result of the interpreter.
 func (in *Interp) SetResult(s string) error {
+	return in.do(func() error { return in.setResult(s) })
+}
+
+func (in *Interp) setResult(s string) error {
 	cs, err := libc.CString(s)
 	if err != nil {
 		return err
@@ -407,3 +655,117 @@ func (in *Interp) SetResult(s string) error {
 	tcl.XTcl_SetResult(in.tls, in.interp, cs, tclVolatile)
 	return nil
 }
+
+// evalObjv evaluates the command consisting of the words objv and returns the
+// return code.
+func (in *Interp) evalObjv(objv []*Obj, flags int32) int32 {
+	sz := len(objv) * int(unsafe.Sizeof(uintptr(0)))
+	p := in.tls.Alloc(sz)
+
+	defer in.tls.Free(sz)
+
+	for i, v := range objv {
+		*(*uintptr)(unsafe.Pointer(p + uintptr(i)*unsafe.Sizeof(uintptr(0)))) = v.p
+	}
+	rc := tcl.XTcl_EvalObjv(in.tls, in.interp, int32(len(objv)), p, flags)
+	runtime.KeepAlive(objv)
+	return rc
+}
+
+// newError returns an *Error reflecting the state of the interpreter after an
+// evaluation completed with return code rc.
+func (in *Interp) newError(rc int32) *Error {
+	err := &Error{
+		Code:    int(rc),
+		Msg:     objString(in.tls, tcl.XTcl_GetObjResult(in.tls, in.interp)),
+		Options: map[string]string{},
+	}
+	opts := tcl.XTcl_GetReturnOptions(in.tls, in.interp, rc)
+	incrRefCount(opts)
+
+	defer decrRefCount(in.tls, opts)
+
+	dictForEach(in.tls, 0, opts, func(keyPtr, valuePtr uintptr) {
+		k := objString(in.tls, keyPtr)
+		err.Options[k] = objString(in.tls, valuePtr)
+		switch k {
+		case "-errorcode":
+			a, _ := listElements(in.tls, 0, valuePtr)
+			for _, v := range a {
+				err.ErrorCode = append(err.ErrorCode, objString(in.tls, v))
+			}
+		case "-errorinfo":
+			err.ErrorInfo = err.Options[k]
+		case "-errorline":
+			err.ErrorLine, _ = strconv.Atoi(err.Options[k])
+		}
+	})
+	return err
+}
+
+// incrRefCount is the equivalent of the Tcl_IncrRefCount macro.
+func incrRefCount(objPtr uintptr) {
+	(*tcl.Tcl_Obj)(unsafe.Pointer(objPtr)).FrefCount++
+}
+
+// decrRefCount is the equivalent of the Tcl_DecrRefCount macro.
+func decrRefCount(tls *libc.TLS, objPtr uintptr) {
+	p := (*tcl.Tcl_Obj)(unsafe.Pointer(objPtr))
+	p.FrefCount--
+	if p.FrefCount <= 0 {
+		tcl.XTclFreeObj(tls, objPtr)
+	}
+}
+
+// objString returns the string representation of the Tcl_Obj at objPtr.
+func objString(tls *libc.TLS, objPtr uintptr) string {
+	bp := tls.Alloc(4)
+
+	defer tls.Free(4)
+
+	p := tcl.XTcl_GetStringFromObj(tls, objPtr, bp)
+	return string(libc.GoBytes(p, int(*(*int32)(unsafe.Pointer(bp)))))
+}
+
+// listElements returns the elements of the Tcl list at listPtr. The elements
+// are owned by the list.
+func listElements(tls *libc.TLS, interp, listPtr uintptr) ([]uintptr, error) {
+	bp := tls.Alloc(2 * 8)
+
+	defer tls.Free(2 * 8)
+
+	if rc := tcl.XTcl_ListObjGetElements(tls, interp, listPtr, bp, bp+8); rc != tcl.TCL_OK {
+		return nil, fmt.Errorf("not a list: %q", objString(tls, listPtr))
+	}
+
+	n := int(*(*int32)(unsafe.Pointer(bp)))
+	objv := *(*uintptr)(unsafe.Pointer(bp + 8))
+	r := make([]uintptr, n)
+	for i := range r {
+		r[i] = *(*uintptr)(unsafe.Pointer(objv + uintptr(i)*unsafe.Sizeof(uintptr(0))))
+	}
+	return r, nil
+}
+
+// dictForEach calls f for every key and value of the Tcl dictionary at
+// dictPtr. The keys and values are owned by the dictionary.
+func dictForEach(tls *libc.TLS, interp, dictPtr uintptr, f func(keyPtr, valuePtr uintptr)) error {
+	sz := int(unsafe.Sizeof(tcl.Tcl_DictSearch{})) + 3*8
+	bp := tls.Alloc(sz)
+
+	defer tls.Free(sz)
+
+	search := bp
+	keyPtr := bp + unsafe.Sizeof(tcl.Tcl_DictSearch{})
+	valuePtr := keyPtr + 8
+	donePtr := valuePtr + 8
+	if rc := tcl.XTcl_DictObjFirst(tls, interp, dictPtr, search, keyPtr, valuePtr, donePtr); rc != tcl.TCL_OK {
+		return fmt.Errorf("missing value to go with key: %q", objString(tls, dictPtr))
+	}
+
+	for ; *(*int32)(unsafe.Pointer(donePtr)) == 0; tcl.XTcl_DictObjNext(tls, search, keyPtr, valuePtr, donePtr) {
+		f(*(*uintptr)(unsafe.Pointer(keyPtr)), *(*uintptr)(unsafe.Pointer(valuePtr)))
+	}
+	tcl.XTcl_DictObjDone(tls, search)
+	return nil
+}
@@ -0,0 +1,275 @@
+// Copyright 2026 The Tcl Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tcl // import "modernc.org/tcl"
+
+import (
+	"fmt"
+	"unsafe"
+
+	"modernc.org/libc"
+	"modernc.org/tcl/lib"
+)
+
+// TraceOp is the operation reported to a TraceProc.
+type TraceOp int
+
+// Variable trace operations. They are combined with GlobalOnly and
+// NamespaceOnly in the flags argument of TraceVar.
+const (
+	TraceReads  TraceOp = tcl.TCL_TRACE_READS  // The variable is read.
+	TraceWrites TraceOp = tcl.TCL_TRACE_WRITES // The variable is written.
+	TraceUnsets TraceOp = tcl.TCL_TRACE_UNSETS // The variable is unset.
+	TraceArray  TraceOp = tcl.TCL_TRACE_ARRAY  // The variable is accessed by the array command.
+)
+
+// String implements fmt.Stringer.
+func (op TraceOp) String() string {
+	switch op {
+	case TraceReads:
+		return "read"
+	case TraceWrites:
+		return "write"
+	case TraceUnsets:
+		return "unset"
+	case TraceArray:
+		return "array"
+	default:
+		return fmt.Sprintf("TraceOp(%#x)", int(op))
+	}
+}
+
+// TraceProc is called when a traced variable is accessed. Name2 is the array
+// element name or the empty string for scalar variables and accesses of the
+// whole array. Returning a non nil error from a read or write trace makes the
+// variable access fail with the error text. Errors returned from unset traces
+// are ignored.
+type TraceProc func(op TraceOp, name1, name2 string) error
+
+// VarTrace represents a variable trace created by TraceVar.
+type VarTrace struct {
+	h     uintptr
+	flags int32
+	in    *Interp
+	name  string
+	proc  TraceProc
+}
+
+var traceVarP = *(*uintptr)(unsafe.Pointer(&struct {
+	f func(tls *libc.TLS, clientData, interp, name1, name2 uintptr, flags int32) uintptr
+}{traceVar}))
+
+func traceVar(tls *libc.TLS, clientData, interp, name1, name2 uintptr, flags int32) uintptr {
+	t := getObject(clientData).(*VarTrace)
+	var r uintptr
+	if op := TraceOp(flags & (tcl.TCL_TRACE_READS | tcl.TCL_TRACE_WRITES | tcl.TCL_TRACE_UNSETS | tcl.TCL_TRACE_ARRAY)); t.flags&int32(op) != 0 {
+		var s2 string
+		if name2 != 0 {
+			s2 = libc.GoString(name2)
+		}
+		var err error
+		t.in.callback(func() { err = t.proc(op, libc.GoString(name1), s2) })
+		if err != nil {
+			// Tcl_DecrRefCount-s the result of a TCL_TRACE_RESULT_OBJECT
+			// trace.
+			r = newStringObj(tls, err.Error())
+			incrRefCount(r)
+		}
+	}
+	if flags&tcl.TCL_TRACE_DESTROYED != 0 {
+		removeObject(t.h)
+		t.h = 0
+	}
+	return r
+}
+
+// TraceVar arranges for proc to be called whenever the variable name is
+// accessed in one of the ways given by flags, a combination of the TraceOp
+// values optionally combined with GlobalOnly or NamespaceOnly. The trace
+// exists until it is removed using VarTrace.Remove, the variable is unset as a
+// whole or the interpreter is closed.
+func (in *Interp) TraceVar(name string, flags TraceOp, proc TraceProc) (r *VarTrace, err error) {
+	err = in.do(func() error { r, err = in.traceVar(name, flags, proc); return err })
+	return r, err
+}
+
+func (in *Interp) traceVar(name string, flags TraceOp, proc TraceProc) (*VarTrace, error) {
+	nm, err := libc.CString(name)
+	if err != nil {
+		return nil, err
+	}
+
+	defer libc.Xfree(in.tls, nm)
+
+	t := &VarTrace{flags: int32(flags), in: in, name: name, proc: proc}
+	t.h = addObject(t)
+	// TCL_TRACE_UNSETS is always requested so the Go side learns about the
+	// trace being destroyed.
+	if rc := tcl.XTcl_TraceVar2(in.tls, in.interp, nm, 0, t.traceFlags(), traceVarP, t.h); rc != tcl.TCL_OK {
+		removeObject(t.h)
+		return nil, in.newError(rc)
+	}
+
+	return t, nil
+}
+
+func (t *VarTrace) traceFlags() int32 {
+	return t.flags | tcl.TCL_TRACE_UNSETS | tcl.TCL_TRACE_RESULT_OBJECT | tcl.TCL_LEAVE_ERR_MSG
+}
+
+// Remove removes the trace. Removing a trace that no longer exists is a
+// no-op.
+func (t *VarTrace) Remove() error {
+	if t.h == 0 {
+		return nil
+	}
+
+	return t.in.do(t.remove)
+}
+
+func (t *VarTrace) remove() error {
+	if t.h == 0 {
+		return nil
+	}
+
+	nm, err := libc.CString(t.name)
+	if err != nil {
+		return err
+	}
+
+	defer libc.Xfree(t.in.tls, nm)
+
+	tcl.XTcl_UntraceVar2(t.in.tls, t.in.interp, nm, 0, t.traceFlags()&^tcl.TCL_LEAVE_ERR_MSG, traceVarP, t.h)
+	removeObject(t.h)
+	t.h = 0
+	return nil
+}
+
+// CmdTraceProc is called before the interpreter executes a command. Level is
+// the nesting level of the command and args are its words. Returning a non
+// nil error makes the command fail with the error text instead of being
+// executed. Commands executed by proc itself are not traced and the
+// interpreter state, including its result, is restored when proc returns.
+type CmdTraceProc func(in *Interp, level int, args []*Obj) error
+
+// CmdTrace represents a command trace created by TraceCommands.
+type CmdTrace struct {
+	busy  bool
+	h     uintptr
+	in    *Interp
+	proc  CmdTraceProc
+	trace uintptr
+}
+
+var (
+	traceCmdP = *(*uintptr)(unsafe.Pointer(&struct {
+		f func(tls *libc.TLS, clientData, interp uintptr, level int32, command, token uintptr, objc int32, objv uintptr) int32
+	}{traceCmd}))
+	traceCmdDeleteP = *(*uintptr)(unsafe.Pointer(&struct {
+		f func(tls *libc.TLS, clientData uintptr)
+	}{removeHandle}))
+)
+
+func traceCmd(tls *libc.TLS, clientData, interp uintptr, level int32, command, token uintptr, objc int32, objv uintptr) int32 {
+	t := getObject(clientData).(*CmdTrace)
+	if t.busy {
+		return tcl.TCL_OK
+	}
+
+	t.busy = true
+
+	defer func() { t.busy = false }()
+
+	args := make([]*Obj, objc)
+	for i := range args {
+		args[i] = t.in.newObj(*(*uintptr)(unsafe.Pointer(objv + uintptr(i)*unsafe.Sizeof(uintptr(0)))))
+	}
+	state := tcl.XTcl_SaveInterpState(tls, interp, tcl.TCL_OK)
+	var err error
+	t.in.callback(func() { err = t.proc(t.in, int(level), args) })
+	if err != nil {
+		tcl.XTcl_DiscardInterpState(tls, state)
+		return t.in.setError(err)
+	}
+
+	tcl.XTcl_RestoreInterpState(tls, interp, state)
+	return tcl.TCL_OK
+}
+
+// TraceCommands arranges for proc to be called before the interpreter
+// executes any command. The trace exists until it is removed using
+// CmdTrace.Remove or the interpreter is closed. While the trace exists,
+// commands are not compiled inline to bytecode, which slows down the
+// interpreter.
+func (in *Interp) TraceCommands(proc CmdTraceProc) (*CmdTrace, error) {
+	t := &CmdTrace{in: in, proc: proc}
+	if err := in.do(func() error {
+		t.h = addObject(t)
+		t.trace = tcl.XTcl_CreateObjTrace(in.tls, in.interp, 0, 0, traceCmdP, t.h, traceCmdDeleteP)
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	return t, nil
+}
+
+// MustTraceCommands is like TraceCommands but panics on error.
+func (in *Interp) MustTraceCommands(proc CmdTraceProc) *CmdTrace {
+	t, err := in.TraceCommands(proc)
+	if err != nil {
+		panic(err)
+	}
+
+	return t
+}
+
+// Remove removes the trace. Removing a trace that was already removed,
+// including the traces of a closed interpreter, is a no-op.
+func (t *CmdTrace) Remove() error {
+	err := t.in.do(func() error {
+		if t.trace != 0 {
+			tcl.XTcl_DeleteTrace(t.in.tls, t.in.interp, t.trace)
+			t.trace = 0
+		}
+		return nil
+	})
+	if err == ErrClosed {
+		return nil
+	}
+
+	return err
+}
+
+// Frame returns the description of a command frame like 'info frame level'
+// does or an error, if any. Level zero is the command being executed, for
+// example the command a CmdTraceProc is called for, negative levels are its
+// callers. The interpreter state is not changed.
+func (in *Interp) Frame(level int) (r map[string]string, err error) {
+	err = in.do(func() error { r, err = in.frame(level); return err })
+	return r, err
+}
+
+func (in *Interp) frame(level int) (map[string]string, error) {
+	if (*tcl.Interp)(unsafe.Pointer(in.interp)).FcmdFramePtr == 0 {
+		return nil, fmt.Errorf("bad level %q", fmt.Sprint(level))
+	}
+
+	state := tcl.XTcl_SaveInterpState(in.tls, in.interp, tcl.TCL_OK)
+
+	defer tcl.XTcl_RestoreInterpState(in.tls, in.interp, state)
+
+	if rc := in.evalObjv([]*Obj{NewStringObj("info"), NewStringObj("frame"), NewIntObj(int64(level))}, 0); rc != tcl.TCL_OK {
+		return nil, in.newError(rc)
+	}
+
+	r := map[string]string{}
+	if err := dictForEach(in.tls, 0, tcl.XTcl_GetObjResult(in.tls, in.interp), func(keyPtr, valuePtr uintptr) {
+		r[objString(in.tls, keyPtr)] = objString(in.tls, valuePtr)
+	}); err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
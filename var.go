@@ -0,0 +1,273 @@
+// Copyright 2026 The Tcl Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tcl // import "modernc.org/tcl"
+
+import (
+	"reflect"
+	"runtime"
+	"sort"
+	"unsafe"
+
+	"modernc.org/libc"
+	"modernc.org/tcl/lib"
+)
+
+// Flags of the variable access methods.
+const (
+	GlobalOnly    = tcl.TCL_GLOBAL_ONLY    // Look up the variable in the global namespace only.
+	NamespaceOnly = tcl.TCL_NAMESPACE_ONLY // Look up the variable in the current namespace only.
+	AppendValue   = tcl.TCL_APPEND_VALUE   // Append to the current value instead of replacing it.
+	ListElement   = tcl.TCL_LIST_ELEMENT   // Append as a list element, implies AppendValue.
+)
+
+// SetVar sets the value of the variable name and returns an error, if any.
+// The value is converted to a Tcl value as described in RegisterFunc. Flags
+// is a combination of GlobalOnly, NamespaceOnly, AppendValue and ListElement.
+func (in *Interp) SetVar(name string, value interface{}, flags int) error {
+	return in.do(func() error { return in.setVar(name, nil, value, flags) })
+}
+
+// SetVar2 is like SetVar but sets the element index of array name.
+func (in *Interp) SetVar2(name, index string, value interface{}, flags int) error {
+	return in.do(func() error { return in.setVar(name, &index, value, flags) })
+}
+
+func (in *Interp) setVar(name1 string, name2 *string, value interface{}, flags int) error {
+	p1, p2, err := varNames(in.tls, name1, name2)
+	if err != nil {
+		return err
+	}
+
+	defer freeVarNames(in.tls, p1, p2)
+
+	o := toObj(reflect.ValueOf(value))
+	if tcl.XTcl_SetVar2Ex(in.tls, in.interp, p1, p2, o.p, int32(flags)|tcl.TCL_LEAVE_ERR_MSG) == 0 {
+		return in.newError(tcl.TCL_ERROR)
+	}
+
+	runtime.KeepAlive(o)
+	return nil
+}
+
+// GetVar returns the value of the variable name or an error, if any. Reading
+// a variable that does not exist is an error. Flags is a combination of
+// GlobalOnly and NamespaceOnly.
+func (in *Interp) GetVar(name string, flags int) (r *Obj, err error) {
+	err = in.do(func() error { r, err = in.getVar(name, nil, flags); return err })
+	return r, err
+}
+
+// GetVar2 is like GetVar but returns the element index of array name.
+func (in *Interp) GetVar2(name, index string, flags int) (r *Obj, err error) {
+	err = in.do(func() error { r, err = in.getVar(name, &index, flags); return err })
+	return r, err
+}
+
+func (in *Interp) getVar(name1 string, name2 *string, flags int) (*Obj, error) {
+	p1, p2, err := varNames(in.tls, name1, name2)
+	if err != nil {
+		return nil, err
+	}
+
+	defer freeVarNames(in.tls, p1, p2)
+
+	p := tcl.XTcl_GetVar2Ex(in.tls, in.interp, p1, p2, int32(flags)|tcl.TCL_LEAVE_ERR_MSG)
+	if p == 0 {
+		return nil, in.newError(tcl.TCL_ERROR)
+	}
+
+	return in.newObj(p), nil
+}
+
+// UnsetVar removes the variable name and returns an error, if any. Removing a
+// variable that does not exist is an error. Flags is a combination of
+// GlobalOnly and NamespaceOnly.
+func (in *Interp) UnsetVar(name string, flags int) error {
+	return in.do(func() error { return in.unsetVar(name, nil, flags) })
+}
+
+// UnsetVar2 is like UnsetVar but removes the element index of array name.
+func (in *Interp) UnsetVar2(name, index string, flags int) error {
+	return in.do(func() error { return in.unsetVar(name, &index, flags) })
+}
+
+func (in *Interp) unsetVar(name1 string, name2 *string, flags int) error {
+	p1, p2, err := varNames(in.tls, name1, name2)
+	if err != nil {
+		return err
+	}
+
+	defer freeVarNames(in.tls, p1, p2)
+
+	if rc := tcl.XTcl_UnsetVar2(in.tls, in.interp, p1, p2, int32(flags)|tcl.TCL_LEAVE_ERR_MSG); rc != tcl.TCL_OK {
+		return in.newError(rc)
+	}
+
+	return nil
+}
+
+// ArrayGet returns the elements of array name or an error, if any. Reading an
+// array that does not exist is an error. Flags may be zero or GlobalOnly.
+func (in *Interp) ArrayGet(name string, flags int) (r map[string]*Obj, err error) {
+	err = in.do(func() error { r, err = in.arrayGet(name, flags); return err })
+	return r, err
+}
+
+func (in *Interp) arrayGet(name string, flags int) (map[string]*Obj, error) {
+	varPtr, err := in.lookupVar(name, nil, flags|tcl.TCL_LEAVE_ERR_MSG)
+	if err != nil {
+		return nil, err
+	}
+
+	if varPtr == 0 || !varIsArray(varPtr) {
+		return nil, &Error{
+			Code:      tcl.TCL_ERROR,
+			Msg:       "can't read \"" + name + "\": no such array",
+			ErrorCode: []string{"TCL", "LOOKUP", "VARNAME", name},
+		}
+	}
+
+	// Like 'array get', collect the element names first, read traces can
+	// modify the array.
+	tls := in.tls
+	sz := int(unsafe.Sizeof(tcl.Tcl_HashSearch{}))
+	search := tls.Alloc(sz)
+	var keys []uintptr
+	table := *(*uintptr)(unsafe.Pointer(varPtr + varValueOffset))
+	for h := tcl.XTcl_FirstHashEntry(tls, table, search); h != 0; h = tcl.XTcl_NextHashEntry(tls, search) {
+		if !varIsUndefined(h - varInHashEntryOffset) {
+			k := *(*uintptr)(unsafe.Pointer(h + hashEntryKeyOffset))
+			incrRefCount(k)
+			keys = append(keys, k)
+		}
+	}
+	tls.Free(sz)
+
+	defer func() {
+		for _, k := range keys {
+			decrRefCount(tls, k)
+		}
+	}()
+
+	nameObj := newStringObj(tls, name)
+	incrRefCount(nameObj)
+
+	defer decrRefCount(tls, nameObj)
+
+	m := make(map[string]*Obj, len(keys))
+	for _, k := range keys {
+		index := objString(tls, k)
+		p := tcl.XTcl_ObjGetVar2(tls, in.interp, nameObj, k, int32(flags)|tcl.TCL_LEAVE_ERR_MSG)
+		if p == 0 {
+			// Skip elements unset by read traces.
+			if elem, _ := in.lookupVar(name, &index, flags); elem == 0 || varIsUndefined(elem) {
+				continue
+			}
+
+			return nil, in.newError(tcl.TCL_ERROR)
+		}
+
+		m[index] = in.newObj(p)
+	}
+	return m, nil
+}
+
+// Flags and field offsets of the Var, VarInHash and Tcl_HashEntry
+// structures, see tclInt.h and tcl.h.
+const (
+	varArray = 0x1 // VAR_ARRAY
+	varLink  = 0x2 // VAR_LINK
+
+	varValueOffset       = unsafe.Offsetof(tcl.Var{}.Fvalue)
+	varInHashEntryOffset = unsafe.Offsetof(tcl.VarInHash{}.Fentry)
+	hashEntryKeyOffset   = unsafe.Offsetof(tcl.Tcl_HashEntry{}.Fkey)
+
+	ptrSize = unsafe.Sizeof(uintptr(0))
+)
+
+// lookupVar returns the Var of the variable name1 or, if name2 is not nil, of
+// its element name2. The variable is not created, varPtr is zero if it does
+// not exist. Flags may include TCL_LEAVE_ERR_MSG, in which case a missing
+// variable is reported as an error.
+func (in *Interp) lookupVar(name1 string, name2 *string, flags int) (varPtr uintptr, err error) {
+	p1, p2, err := varNames(in.tls, name1, name2)
+	if err != nil {
+		return 0, err
+	}
+
+	defer freeVarNames(in.tls, p1, p2)
+
+	msg, err := libc.CString("read")
+	if err != nil {
+		return 0, err
+	}
+
+	defer libc.Xfree(in.tls, msg)
+
+	sz := int(ptrSize)
+	arrayPtr := in.tls.Alloc(sz)
+
+	defer in.tls.Free(sz)
+
+	if varPtr = tcl.XTclLookupVar(in.tls, in.interp, p1, p2, int32(flags), msg, 0, 0, arrayPtr); varPtr == 0 && flags&tcl.TCL_LEAVE_ERR_MSG != 0 {
+		return 0, in.newError(tcl.TCL_ERROR)
+	}
+
+	return varPtr, nil
+}
+
+// varIsArray reports whether the Var at varPtr is an array.
+func varIsArray(varPtr uintptr) bool {
+	return *(*int32)(unsafe.Pointer(varPtr))&varArray != 0
+}
+
+// varIsUndefined reports whether the Var at varPtr has no value, for example
+// because it was unset.
+func varIsUndefined(varPtr uintptr) bool {
+	return *(*int32)(unsafe.Pointer(varPtr))&(varArray|varLink) == 0 && *(*uintptr)(unsafe.Pointer(varPtr + varValueOffset)) == 0
+}
+
+// ArraySet sets the elements of array name to the values in m and returns an
+// error, if any. Existing elements not present in m are left intact. Flags is
+// a combination of GlobalOnly and NamespaceOnly.
+func (in *Interp) ArraySet(name string, m map[string]interface{}, flags int) error {
+	return in.do(func() error { return in.arraySet(name, m, flags) })
+}
+
+func (in *Interp) arraySet(name string, m map[string]interface{}, flags int) error {
+	var keys []string
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		if err := in.setVar(name, &k, m[k], flags); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func varNames(tls *libc.TLS, name1 string, name2 *string) (p1, p2 uintptr, err error) {
+	if p1, err = libc.CString(name1); err != nil {
+		return 0, 0, err
+	}
+
+	if name2 != nil {
+		if p2, err = libc.CString(*name2); err != nil {
+			libc.Xfree(tls, p1)
+			return 0, 0, err
+		}
+	}
+
+	return p1, p2, nil
+}
+
+func freeVarNames(tls *libc.TLS, p1, p2 uintptr) {
+	libc.Xfree(tls, p1)
+	if p2 != 0 {
+		libc.Xfree(tls, p2)
+	}
+}
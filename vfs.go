@@ -10,8 +10,6 @@ package tcl // import "modernc.org/tcl"
 
 import (
 	"fmt"
-	"io"
-	"math"
 	"net/http"
 	"os"
 	"path"
@@ -23,8 +21,6 @@ import (
 
 	"modernc.org/httpfs"
 	"modernc.org/libc"
-	"modernc.org/libc/errno"
-	"modernc.org/mathutil"
 	"modernc.org/tcl/lib"
 )
 
@@ -240,7 +236,7 @@ func vfsOpenFileChannel(tls *libc.TLS, interp uintptr, pathPtr uintptr, mode int
 		return 0
 	}
 
-	return tcl.XTcl_CreateChannel(tls, uintptr(unsafe.Pointer(&channel)), cPath, addObject(file), tcl.TCL_READABLE)
+	return tcl.XTcl_CreateChannel(tls, uintptr(unsafe.Pointer(&channel)), cPath, addObject(&goChannel{c: file, r: file, s: file}), tcl.TCL_READABLE)
 }
 
 // Function to process a Tcl_FSMatchInDirectory call. If not implemented, then
@@ -460,193 +456,3 @@ func vfsMatch(path string, i int) (string, *fileSystem) {
 
 	return "", nil
 }
-
-var channel = tcl.Tcl_ChannelType{
-	FtypeName: uintptr(unsafe.Pointer(&cVFSName[0])),
-	Fversion:  tclChannelVersion_2,
-	FcloseProc: *(*uintptr)(unsafe.Pointer(&struct {
-		f func(tls *libc.TLS, instanceData tcl.ClientData, interp uintptr) int32
-	}{channelClose})),
-	FinputProc: *(*uintptr)(unsafe.Pointer(&struct {
-		f func(tls *libc.TLS, instanceData tcl.ClientData, buf uintptr, toRead int32, errorCodePtr uintptr) int32
-	}{channelInput})),
-	FseekProc: *(*uintptr)(unsafe.Pointer(&struct {
-		f func(tls *libc.TLS, instanceData tcl.ClientData, offset int64, mode int32, errorCodePtr uintptr) int32
-	}{channelSeek})),
-	FwatchProc: *(*uintptr)(unsafe.Pointer(&struct {
-		f func(tls *libc.TLS, instanceData tcl.ClientData, mask int32)
-	}{channelWatch})),
-	FwideSeekProc: *(*uintptr)(unsafe.Pointer(&struct {
-		f func(tls *libc.TLS, instanceData tcl.ClientData, offset tcl.Tcl_WideInt, mode int32, errorCodePtr uintptr) tcl.Tcl_WideInt
-	}{channelWideSeek})),
-}
-
-// The closeProc field contains the address of a function called by the generic
-// layer to clean up driver-related information when the channel is closed.
-// CloseProc must match the following prototype:
-//
-// The instanceData argument is the same as the value provided to
-// Tcl_CreateChannel when the channel was created. The function should release
-// any storage maintained by the channel driver for this channel, and close the
-// input and output devices encapsulated by this channel. All queued output
-// will have been flushed to the device before this function is called, and no
-// further driver operations will be invoked on this instance after calling the
-// closeProc. If the close operation is successful, the procedure should return
-// zero; otherwise it should return a nonzero POSIX error code. In addition, if
-// an error occurs and interp is not NULL, the procedure should store an error
-// message in the interpreter's result.
-func channelClose(tls *libc.TLS, instanceData tcl.ClientData, interp uintptr) int32 {
-	removeObject(instanceData)
-	return 0
-}
-
-// The inputProc field contains the address of a function called by the generic
-// layer to read data from the file or device and store it in an internal
-// buffer. InputProc must match the following prototype:
-//
-// InstanceData is the same as the value passed to Tcl_CreateChannel when the
-// channel was created. The buf argument points to an array of bytes in which
-// to store input from the device, and the bufSize argument indicates how many
-// bytes are available at buf.
-//
-// The errorCodePtr argument points to an integer variable provided by the
-// generic layer. If an error occurs, the function should set the variable to a
-// POSIX error code that identifies the error that occurred.
-//
-// The function should read data from the input device encapsulated by the
-// channel and store it at buf. On success, the function should return a
-// nonnegative integer indicating how many bytes were read from the input
-// device and stored at buf. On error, the function should return -1. If an
-// error occurs after some data has been read from the device, that data is
-// lost.
-//
-// If inputProc can determine that the input device has some data available but
-// less than requested by the bufSize argument, the function should only
-// attempt to read as much data as is available and return without blocking. If
-// the input device has no data available whatsoever and the channel is in
-// nonblocking mode, the function should return an EAGAIN error. If the input
-// device has no data available whatsoever and the channel is in blocking mode,
-// the function should block for the shortest possible time until at least one
-// byte of data can be read from the device; then, it should return as much
-// data as it can read without blocking.
-//
-// This value can be retrieved with Tcl_ChannelInputProc, which returns a
-// pointer to the function.
-func channelInput(tls *libc.TLS, instanceData tcl.ClientData, buf uintptr, toRead int32, errorCodePtr uintptr) int32 {
-	if buf == 0 || toRead == 0 {
-		return 0
-	}
-
-	n, err := getObject(instanceData).(http.File).Read((*libc.RawMem)(unsafe.Pointer(buf))[:toRead:toRead])
-	if n != 0 {
-		return int32(n)
-	}
-
-	if err != nil && err != io.EOF {
-		return -1
-	}
-
-	return 0
-}
-
-// The seekProc field contains the address of a function called by the generic
-// layer to move the access point at which subsequent input or output
-// operations will be applied. SeekProc must match the following prototype:
-//
-// The instanceData argument is the same as the value given to
-// Tcl_CreateChannel when this channel was created. Offset and seekMode have
-// the same meaning as for the Tcl_Seek procedure (described in the manual
-// entry for Tcl_OpenFileChannel).
-//
-// The errorCodePtr argument points to an integer variable provided by the
-// generic layer for returning errno values from the function. The function
-// should set this variable to a POSIX error code if an error occurs. The
-// function should store an EINVAL error code if the channel type does not
-// implement seeking.
-//
-// The return value is the new access point or -1 in case of error. If an error
-// occurred, the function should not move the access point.
-func channelSeek(tls *libc.TLS, instanceData tcl.ClientData, offset int64, mode int32, errorCodePtr uintptr) (r int32) {
-	e := int32(errno.EINVAL)
-	defer func() {
-		if r < 0 && errorCodePtr != 0 {
-			*(*int32)(unsafe.Pointer(errorCodePtr)) = e
-		}
-	}()
-
-	if offset < mathutil.MinInt || offset > mathutil.MaxInt {
-		return -1
-	}
-
-	file := getObject(instanceData).(http.File)
-	n0, err := file.Seek(0, os.SEEK_CUR)
-	if err != nil {
-		return -1
-	}
-
-	n, err := file.Seek(offset, int(mode))
-	if err != nil {
-		return -1
-	}
-
-	if n > math.MaxInt32 {
-		e = errno.EOVERFLOW
-		file.Seek(n0, os.SEEK_SET)
-		return -1
-	}
-
-	return int32(n)
-}
-
-// If there is a non-NULL seekProc field, the wideSeekProc field may contain
-// the address of an alternative function to use which handles wide (i.e.
-// larger than 32-bit) offsets, so allowing seeks within files larger than 2GB.
-// The wideSeekProc will be called in preference to the seekProc, but both must
-// be defined if the wideSeekProc is defined. WideSeekProc must match the
-// following prototype:
-//
-// The arguments and return values mean the same thing as with seekProc above,
-// except that the type of offsets and the return type are different.
-//
-// The seekProc value can be retrieved with Tcl_ChannelSeekProc, which returns
-// a pointer to the function, and similarly the wideSeekProc can be retrieved
-// with Tcl_ChannelWideSeekProc.
-func channelWideSeek(tls *libc.TLS, instanceData tcl.ClientData, offset tcl.Tcl_WideInt, mode int32, errorCodePtr uintptr) tcl.Tcl_WideInt {
-	file := getObject(instanceData).(http.File)
-	n, err := file.Seek(offset, int(mode))
-	if err != nil {
-		if errorCodePtr != 0 {
-			*(*int32)(unsafe.Pointer(errorCodePtr)) = errno.EINVAL
-		}
-		return -1
-	}
-
-	return tcl.Tcl_WideInt(n)
-}
-
-// The watchProc field contains the address of a function called by the generic
-// layer to initialize the event notification mechanism to notice events of
-// interest on this channel. WatchProc should match the following prototype:
-//
-// The instanceData is the same as the value passed to Tcl_CreateChannel when
-// this channel was created. The mask argument is an OR-ed combination of
-// TCL_READABLE, TCL_WRITABLE and TCL_EXCEPTION; it indicates events the caller
-// is interested in noticing on this channel.
-//
-// The function should initialize device type specific mechanisms to notice
-// when an event of interest is present on the channel. When one or more of the
-// designated events occurs on the channel, the channel driver is responsible
-// for calling Tcl_NotifyChannel to inform the generic channel module. The
-// driver should take care not to starve other channel drivers or sources of
-// callbacks by invoking Tcl_NotifyChannel too frequently. Fairness can be
-// insured by using the Tcl event queue to allow the channel event to be
-// scheduled in sequence with other events. See the description of
-// Tcl_QueueEvent for details on how to queue an event.
-//
-// This value can be retrieved with Tcl_ChannelWatchProc, which returns a
-// pointer to the function.
-func channelWatch(tls *libc.TLS, instanceData tcl.ClientData, mask int32) {
-	if mask != 0 {
-		panic(todo(""))
-	}
-}